@@ -33,6 +33,21 @@ type Config struct {
 	RefreshInterval time.Duration        `json:"refresh_interval,format:units"`
 	Submitter       Submitter            `json:"submitter"`
 	Submitters      map[string]Submitter `json:"submitters"`
+	Stats           StatsReport          `json:"stats,omitzero"`
+
+	// Profile enables measuring the time spent in every submitter and waiting on the locks of
+	// the hydrator, grouper and trace buffer submitters. It is reported in the tree.
+	Profile bool `json:"profile,omitzero"`
+}
+
+// StatsReport configures the periodic hydrant.pipeline.stats events describing the pipeline
+// itself. They are only sent if Submitter is set.
+type StatsReport struct {
+	// Interval is how often the stats are sent. It defaults to one minute.
+	Interval time.Duration `json:"interval,omitzero,format:units"`
+
+	// Submitter is the name of the submitter in Submitters that receives the stats events.
+	Submitter string `json:"submitter"`
 }
 
 // MarshalJSON implements the encoding/json Marshaler interface.
@@ -55,44 +70,187 @@ type (
 	NamedSubmitter string
 
 	FilterSubmitter struct {
-		Filter    string    `json:"filter"`
-		Submitter Submitter `json:"submitter"`
+		Filter         string    `json:"filter"`
+		Submitter      Submitter `json:"submitter"`
+		LiveBufferSize int       `json:"live_buffer_size,omitzero"`
 	}
 
 	GrouperSubmitter struct {
-		FlushInterval time.Duration `json:"flush_interval,format:units"`
-		GroupBy       []string      `json:"group_by"`
-		Submitter     Submitter     `json:"submitter"`
+		FlushInterval  time.Duration `json:"flush_interval,format:units"`
+		GroupBy        []string      `json:"group_by"`
+		Submitter      Submitter     `json:"submitter"`
+		LiveBufferSize int           `json:"live_buffer_size,omitzero"`
 	}
 
 	HTTPSubmitter struct {
-		ProcessFields []string      `json:"process_fields"`
-		Endpoint      string        `json:"endpoint"`
-		FlushInterval time.Duration `json:"flush_interval,format:units"`
-		MaxBatchSize  int           `json:"max_batch_size"`
+		ProcessFields  []string      `json:"process_fields"`
+		Endpoint       string        `json:"endpoint" secret:"true"`
+		FlushInterval  time.Duration `json:"flush_interval,format:units"`
+		MaxBatchSize   int           `json:"max_batch_size"`
+		LiveBufferSize int           `json:"live_buffer_size,omitzero"`
 	}
 
 	OTelSubmitter struct {
-		ProcessFields []string      `json:"process_fields"`
-		Endpoint      string        `json:"endpoint"`
-		FlushInterval time.Duration `json:"flush_interval,format:units"`
-		MaxBatchSize  int           `json:"max_batch_size"`
+		ProcessFields  []string      `json:"process_fields"`
+		Endpoint       string        `json:"endpoint" secret:"true"`
+		FlushInterval  time.Duration `json:"flush_interval,format:units"`
+		MaxBatchSize   int           `json:"max_batch_size"`
+		LiveBufferSize int           `json:"live_buffer_size,omitzero"`
 	}
 
 	PrometheusSubmitter struct {
-		Namespace string    `json:"namespace"`
-		Buckets   []float64 `json:"buckets"`
+		ID             string    `json:"id,omitempty"`
+		Namespace      string    `json:"namespace"`
+		Buckets        []float64 `json:"buckets"`
+		LiveBufferSize int       `json:"live_buffer_size,omitzero"`
 	}
 
 	HydratorSubmitter struct {
+		ID             string `json:"id,omitempty"`
+		LiveBufferSize int    `json:"live_buffer_size,omitzero"`
 	}
 
 	TraceBufferSubmitter struct {
-		BufferSize int    `json:"buffer_size"`
-		Filter     string `json:"filter"`
+		ID             string `json:"id,omitempty"`
+		BufferSize     int    `json:"buffer_size"`
+		Filter         string `json:"filter"`
+		LiveBufferSize int    `json:"live_buffer_size,omitzero"`
+	}
+
+	// FileSubmitter writes events to rotating segments in Dir. Format is native or jsonl,
+	// and Sync is never, rotate, flush or always.
+	FileSubmitter struct {
+		Dir            string        `json:"dir"`
+		Format         string        `json:"format,omitzero"`
+		RotateSize     int64         `json:"rotate_size,omitzero"`
+		RotateInterval time.Duration `json:"rotate_interval,omitzero,format:units"`
+		Compress       bool          `json:"compress,omitzero"`
+		MaxSegments    int           `json:"max_segments,omitzero"`
+		MaxAge         time.Duration `json:"max_age,omitzero,format:units"`
+		Sync           string        `json:"sync,omitzero"`
+		FlushInterval  time.Duration `json:"flush_interval,omitzero,format:units"`
+		MaxBatchSize   int           `json:"max_batch_size,omitzero"`
+		LiveBufferSize int           `json:"live_buffer_size,omitzero"`
+	}
+
+	// ConsoleSubmitter writes events to a terminal. Output is stdout or stderr, the default,
+	// Format is logfmt, pretty or tree, and Color is auto, always or never.
+	ConsoleSubmitter struct {
+		Output         string `json:"output,omitzero"`
+		Format         string `json:"format,omitzero"`
+		Color          string `json:"color,omitzero"`
+		Verbose        bool   `json:"verbose,omitzero"`
+		LiveBufferSize int    `json:"live_buffer_size,omitzero"`
+	}
+
+	// SyslogSubmitter sends log events to a syslog daemon as RFC 5424 messages. Network is udp,
+	// tcp or unix, for the local socket at Address or the usual paths.
+	SyslogSubmitter struct {
+		Network        string        `json:"network"`
+		Address        string        `json:"address,omitzero"`
+		Facility       string        `json:"facility,omitzero"`
+		Hostname       string        `json:"hostname,omitzero"`
+		AppName        string        `json:"app_name,omitzero"`
+		SDID           string        `json:"sd_id,omitzero"`
+		FlushInterval  time.Duration `json:"flush_interval,omitzero,format:units"`
+		MaxBatchSize   int           `json:"max_batch_size,omitzero"`
+		LiveBufferSize int           `json:"live_buffer_size,omitzero"`
+	}
+
+	// StatsDSubmitter sends grouped events and spans to a StatsD daemon at Address over UDP. Tags
+	// sends the group keys, and the TagKeys of spans, as DogStatsD tags.
+	StatsDSubmitter struct {
+		Address        string        `json:"address"`
+		Prefix         string        `json:"prefix,omitzero"`
+		Tags           bool          `json:"tags,omitzero"`
+		TagKeys        []string      `json:"tag_keys,omitzero"`
+		PacketSize     int           `json:"packet_size,omitzero"`
+		FlushInterval  time.Duration `json:"flush_interval,omitzero,format:units"`
+		MaxBatchSize   int           `json:"max_batch_size,omitzero"`
+		LiveBufferSize int           `json:"live_buffer_size,omitzero"`
+	}
+
+	SamplerSubmitter struct {
+		Mode           string    `json:"mode"`
+		Probability    float64   `json:"probability,omitzero"`
+		Rate           float64   `json:"rate,omitzero"`
+		Burst          int       `json:"burst,omitzero"`
+		Key            string    `json:"key,omitzero"`
+		Submitter      Submitter `json:"submitter"`
+		LiveBufferSize int       `json:"live_buffer_size,omitzero"`
+	}
+
+	TailSamplerSubmitter struct {
+		Timeout        time.Duration `json:"timeout,omitzero,format:units"`
+		MaxTraces      int           `json:"max_traces,omitzero"`
+		MaxSpans       int           `json:"max_spans,omitzero"`
+		Errors         bool          `json:"errors,omitzero"`
+		MinDuration    time.Duration `json:"min_duration,omitzero,format:units"`
+		Filter         string        `json:"filter,omitzero"`
+		Probability    float64       `json:"probability,omitzero"`
+		Submitter      Submitter     `json:"submitter"`
+		LiveBufferSize int           `json:"live_buffer_size,omitzero"`
+	}
+
+	DedupSubmitter struct {
+		Fields         []string      `json:"fields,omitzero"`
+		Window         time.Duration `json:"window,omitzero,format:units"`
+		Submitter      Submitter     `json:"submitter"`
+		LiveBufferSize int           `json:"live_buffer_size,omitzero"`
+	}
+
+	TransformSubmitter struct {
+		Operations     []TransformOperation `json:"operations"`
+		Submitter      Submitter            `json:"submitter"`
+		LiveBufferSize int                  `json:"live_buffer_size,omitzero"`
+	}
+
+	// TransformOperation is one step of a TransformSubmitter. Op is one of drop, keep, rename,
+	// set, copy, hash, truncate, convert or lookup, and decides which of the other fields are
+	// used.
+	TransformOperation struct {
+		Op      string            `json:"op"`
+		Keys    []string          `json:"keys,omitzero"`
+		Key     string            `json:"key,omitzero"`
+		To      string            `json:"to,omitzero"`
+		Value   any               `json:"value,omitzero"`
+		Salt    string            `json:"salt,omitzero" secret:"true"`
+		Length  int               `json:"length,omitzero"`
+		As      string            `json:"as,omitzero"`
+		Table   map[string]string `json:"table,omitzero"`
+		Default string            `json:"default,omitzero"`
+	}
+
+	// RedactSubmitter removes sensitive data from events. Detectors are the names of built-in
+	// rules to apply, defaulting to all of them, and Rules are more. Mode is mask, the default,
+	// or hash, which requires a Salt.
+	RedactSubmitter struct {
+		Detectors      []string     `json:"detectors,omitzero"`
+		Rules          []RedactRule `json:"rules,omitzero"`
+		DenyKeys       []string     `json:"deny_keys,omitzero"`
+		Mode           string       `json:"mode,omitzero"`
+		Salt           string       `json:"salt,omitzero" secret:"true"`
+		Submitter      Submitter    `json:"submitter"`
+		LiveBufferSize int          `json:"live_buffer_size,omitzero"`
+	}
+
+	// RedactRule is a custom rule of a RedactSubmitter that redacts the matches of Pattern.
+	RedactRule struct {
+		Name    string `json:"name"`
+		Pattern string `json:"pattern"`
+	}
+
+	ExtractSubmitter struct {
+		Key            string    `json:"key,omitzero"`
+		Format         string    `json:"format"`
+		Pattern        string    `json:"pattern,omitzero"`
+		Prefix         string    `json:"prefix,omitzero"`
+		Submitter      Submitter `json:"submitter"`
+		LiveBufferSize int       `json:"live_buffer_size,omitzero"`
 	}
 
 	NullSubmitter struct {
+		LiveBufferSize int `json:"live_buffer_size,omitzero"`
 	}
 )
 
@@ -105,6 +263,16 @@ func (OTelSubmitter) isSubmitter()        {}
 func (PrometheusSubmitter) isSubmitter()  {}
 func (HydratorSubmitter) isSubmitter()    {}
 func (TraceBufferSubmitter) isSubmitter() {}
+func (FileSubmitter) isSubmitter()        {}
+func (ConsoleSubmitter) isSubmitter()     {}
+func (SyslogSubmitter) isSubmitter()      {}
+func (StatsDSubmitter) isSubmitter()      {}
+func (SamplerSubmitter) isSubmitter()     {}
+func (TailSamplerSubmitter) isSubmitter() {}
+func (DedupSubmitter) isSubmitter()       {}
+func (TransformSubmitter) isSubmitter()   {}
+func (RedactSubmitter) isSubmitter()      {}
+func (ExtractSubmitter) isSubmitter()     {}
 func (NullSubmitter) isSubmitter()        {}
 
 //
@@ -150,6 +318,36 @@ func UnmarshalSubmitter(dec *jsontext.Decoder, dst *Submitter) error {
 		case "trace_buffer":
 			return unmarshalOneSubmitter[TraceBufferSubmitter](raw, dst)
 
+		case "sampler":
+			return unmarshalOneSubmitter[SamplerSubmitter](raw, dst)
+
+		case "tail_sampler":
+			return unmarshalOneSubmitter[TailSamplerSubmitter](raw, dst)
+
+		case "dedup":
+			return unmarshalOneSubmitter[DedupSubmitter](raw, dst)
+
+		case "transform":
+			return unmarshalOneSubmitter[TransformSubmitter](raw, dst)
+
+		case "file":
+			return unmarshalOneSubmitter[FileSubmitter](raw, dst)
+
+		case "console":
+			return unmarshalOneSubmitter[ConsoleSubmitter](raw, dst)
+
+		case "syslog":
+			return unmarshalOneSubmitter[SyslogSubmitter](raw, dst)
+
+		case "statsd":
+			return unmarshalOneSubmitter[StatsDSubmitter](raw, dst)
+
+		case "redact":
+			return unmarshalOneSubmitter[RedactSubmitter](raw, dst)
+
+		case "extract":
+			return unmarshalOneSubmitter[ExtractSubmitter](raw, dst)
+
 		case "null":
 			return unmarshalOneSubmitter[NullSubmitter](raw, dst)
 
@@ -247,6 +445,46 @@ func MarshalSubmitter(enc *jsontext.Encoder, src Submitter) error {
 		type traceBufferSubmitter TraceBufferSubmitter // prevent recursion
 		return marhsalOneSubmitter(enc, "trace_buffer", (*traceBufferSubmitter)(cfg))
 
+	case *SamplerSubmitter:
+		type samplerSubmitter SamplerSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "sampler", (*samplerSubmitter)(cfg))
+
+	case *TailSamplerSubmitter:
+		type tailSamplerSubmitter TailSamplerSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "tail_sampler", (*tailSamplerSubmitter)(cfg))
+
+	case *DedupSubmitter:
+		type dedupSubmitter DedupSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "dedup", (*dedupSubmitter)(cfg))
+
+	case *TransformSubmitter:
+		type transformSubmitter TransformSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "transform", (*transformSubmitter)(cfg))
+
+	case *FileSubmitter:
+		type fileSubmitter FileSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "file", (*fileSubmitter)(cfg))
+
+	case *ConsoleSubmitter:
+		type consoleSubmitter ConsoleSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "console", (*consoleSubmitter)(cfg))
+
+	case *SyslogSubmitter:
+		type syslogSubmitter SyslogSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "syslog", (*syslogSubmitter)(cfg))
+
+	case *StatsDSubmitter:
+		type statsDSubmitter StatsDSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "statsd", (*statsDSubmitter)(cfg))
+
+	case *RedactSubmitter:
+		type redactSubmitter RedactSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "redact", (*redactSubmitter)(cfg))
+
+	case *ExtractSubmitter:
+		type extractSubmitter ExtractSubmitter // prevent recursion
+		return marhsalOneSubmitter(enc, "extract", (*extractSubmitter)(cfg))
+
 	case *NullSubmitter:
 		type nullSubmitter NullSubmitter // prevent recursion
 		return marhsalOneSubmitter(enc, "null", (*nullSubmitter)(cfg))
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+)
+
+// redacted is the value that replaces secrets.
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the config with every submitter field tagged `secret:"true"` passed
+// through Redact so that it can be shown to people who should not see credentials.
+func (c Config) Redacted() Config {
+	c.Submitter = redactSubmitter(c.Submitter)
+	if c.Submitters != nil {
+		subs := make(map[string]Submitter, len(c.Submitters))
+		for name, sub := range c.Submitters {
+			subs[name] = redactSubmitter(sub)
+		}
+		c.Submitters = subs
+	}
+	return c
+}
+
+func redactSubmitter(sub Submitter) Submitter {
+	switch sub := sub.(type) {
+	case nil, NamedSubmitter:
+		return sub
+
+	case MultiSubmitter:
+		subs := make(MultiSubmitter, len(sub))
+		for i, sub := range sub {
+			subs[i] = redactSubmitter(sub)
+		}
+		return subs
+	}
+
+	v := reflect.New(reflect.TypeOf(sub)).Elem()
+	v.Set(reflect.ValueOf(sub))
+	redactFields(v)
+	return v.Interface().(Submitter)
+}
+
+// redactFields redacts the fields of the settable struct v, including the ones in nested
+// submitters and slices of structs. Slices are copied before they are changed.
+func redactFields(v reflect.Value) {
+	for i := range v.NumField() {
+		field, fv := v.Type().Field(i), v.Field(i)
+		switch {
+		case field.Type == reflect.TypeFor[Submitter]():
+			if !fv.IsNil() {
+				fv.Set(reflect.ValueOf(redactSubmitter(fv.Interface().(Submitter))))
+			}
+
+		case field.Tag.Get("secret") == "true" && fv.Kind() == reflect.String:
+			fv.SetString(Redact(fv.String()))
+
+		case fv.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			if fv.IsNil() {
+				continue
+			}
+			cp := reflect.MakeSlice(field.Type, fv.Len(), fv.Len())
+			reflect.Copy(cp, fv)
+			for j := range cp.Len() {
+				redactFields(cp.Index(j))
+			}
+			fv.Set(cp)
+		}
+	}
+}
+
+// Redact hides the credentials in a secret value. URLs keep their scheme, host and path, but
+// passwords and query parameter values are replaced. Anything else is replaced entirely.
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	u, err := url.Parse(secret)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return redacted
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		} else {
+			u.User = url.User(redacted)
+		}
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{redacted}
+		}
+		u.RawQuery = query.Encode()
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
@@ -0,0 +1,240 @@
+package configserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant/config"
+)
+
+// maxWait bounds how long a client may ask the server to hold a request.
+const maxWait = 5 * time.Minute
+
+// ServeHTTP serves configs to clients at /config and the admin API under /admin, without any
+// authentication. See Handler for details.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Handler returns the http.Handler for the server, serving ConfigHandler at /config and
+// AdminHandler under /admin. The admin API can change the config of every client and has no
+// authentication of its own, so only serve this handler on a trusted network. Otherwise mount
+// ConfigHandler and AdminHandler separately and put the admin API behind a submitters.Access.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// ConfigHandler returns the http.Handler clients fetch their config from, sending their process
+// annotations as query parameters.
+func (s *Server) ConfigHandler() http.Handler {
+	return http.HandlerFunc(s.serveConfig)
+}
+
+// AdminHandler returns the http.Handler for the admin API:
+//
+//	GET  /versions        list versions
+//	POST /versions        store the config in the body as a new version
+//	GET  /versions/{id}   get a version
+//	POST /publish         store the config in the body and release it to everyone
+//	GET  /release         get the current release
+//	PUT  /release         set the release in the body
+//	GET  /history         list releases, oldest first
+//	POST /rollback        return to the previous release
+//	GET  /select          show the version a client with the query's annotations gets
+//
+// Versions and publish accept a comment query parameter. It does no authentication itself.
+func (s *Server) AdminHandler() http.Handler {
+	return hmux.Dir{
+		"/versions": hmux.Dir{
+			"": hmux.Method{
+				"GET":  http.HandlerFunc(s.serveVersions),
+				"POST": http.HandlerFunc(s.serveAddVersion),
+			},
+			"*": hmux.Arg("id").Capture(hmux.Method{
+				"GET": http.HandlerFunc(s.serveVersion),
+			}),
+		},
+		"/publish": hmux.Method{"POST": http.HandlerFunc(s.servePublish)},
+		"/release": hmux.Method{
+			"GET": http.HandlerFunc(s.serveRelease),
+			"PUT": http.HandlerFunc(s.serveSetRelease),
+		},
+		"/history":  hmux.Method{"GET": http.HandlerFunc(s.serveHistory)},
+		"/rollback": hmux.Method{"POST": http.HandlerFunc(s.serveRollback)},
+		"/select":   hmux.Method{"GET": http.HandlerFunc(s.serveSelect)},
+	}
+}
+
+func (s *Server) newHandler() http.Handler {
+	return hmux.Dir{
+		"/config": s.ConfigHandler(),
+		"/admin":  s.AdminHandler(),
+	}
+}
+
+// serveConfig serves the config selected for the client. It supports conditional requests with
+// If-None-Match and long polling with a "Prefer: wait=N" header.
+func (s *Server) serveConfig(w http.ResponseWriter, r *http.Request) {
+	annotations := queryAnnotations(r)
+	etag := r.Header.Get("If-None-Match")
+
+	var deadline <-chan time.Time
+	if wait, ok := strings.CutPrefix(r.Header.Get("Prefer"), "wait="); ok {
+		if secs, err := strconv.Atoi(wait); err == nil && secs > 0 {
+			w.Header().Set("Preference-Applied", "wait="+wait)
+			deadline = time.After(min(time.Duration(secs)*time.Second, maxWait))
+		}
+	}
+
+	for {
+		s.mu.Lock()
+		v, changed := s.selectLocked(annotations), s.changed
+		s.mu.Unlock()
+
+		if v != nil && v.etag != etag {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", v.etag)
+			w.Write(v.data)
+			return
+		}
+
+		if deadline == nil {
+			writeUnavailable(w, v)
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline:
+			writeUnavailable(w, v)
+			return
+		case <-changed:
+		}
+	}
+}
+
+// writeUnavailable responds when there is no new config for the client: either it already has
+// the selected version or nothing has been released.
+func writeUnavailable(w http.ResponseWriter, v *Version) {
+	if v != nil {
+		w.WriteHeader(http.StatusNotModified)
+	} else {
+		http.Error(w, "no config released", http.StatusNotFound)
+	}
+}
+
+func queryAnnotations(r *http.Request) map[string]string {
+	query := r.URL.Query()
+	annotations := make(map[string]string, len(query))
+	for key, values := range query {
+		if len(values) > 0 {
+			annotations[key] = values[0]
+		}
+	}
+	return annotations
+}
+
+func (s *Server) serveVersions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Versions())
+}
+
+func (s *Server) serveVersion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(hmux.Arg("id").Value(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	v, ok := s.Version(id)
+	if !ok {
+		http.Error(w, "unknown version", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, v)
+}
+
+func (s *Server) serveAddVersion(w http.ResponseWriter, r *http.Request) {
+	s.serveNewConfig(w, r, s.AddVersion)
+}
+
+func (s *Server) servePublish(w http.ResponseWriter, r *http.Request) {
+	s.serveNewConfig(w, r, s.Publish)
+}
+
+func (s *Server) serveNewConfig(w http.ResponseWriter, r *http.Request, fn func(config.Config, string) (int, error)) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var cfg config.Config
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := fn(cfg, r.URL.Query().Get("comment"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]int{"id": id})
+}
+
+func (s *Server) serveRelease(w http.ResponseWriter, r *http.Request) {
+	rel, ok := s.Release()
+	if !ok {
+		http.Error(w, "no config released", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, rel)
+}
+
+func (s *Server) serveSetRelease(w http.ResponseWriter, r *http.Request) {
+	var rel Release
+	if err := json.NewDecoder(r.Body).Decode(&rel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.SetRelease(rel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.serveRelease(w, r)
+}
+
+func (s *Server) serveHistory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.History())
+}
+
+func (s *Server) serveRollback(w http.ResponseWriter, r *http.Request) {
+	rel, err := s.Rollback()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, rel)
+}
+
+func (s *Server) serveSelect(w http.ResponseWriter, r *http.Request) {
+	v, ok := s.Select(queryAnnotations(r))
+	if !ok {
+		http.Error(w, "no config released", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, v)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(v)
+}
@@ -0,0 +1,300 @@
+// Package configserver serves pipeline configs to RemoteSubmitters. It stores
+// versioned configs, picks a version per client by matching the process
+// annotations the client sends, supports percentage rollouts hashed on the
+// client's hostname, and keeps a history of releases for rollback.
+package configserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs/v2"
+
+	"storj.io/hydrant/config"
+)
+
+// HostnameKey is the process annotation used to place clients into percentage rollouts.
+const HostnameKey = "os.hostname"
+
+// Version is a stored config.
+type Version struct {
+	ID      int           `json:"id"`
+	Created time.Time     `json:"created"`
+	Comment string        `json:"comment,omitempty"`
+	Config  config.Config `json:"config"`
+
+	data []byte
+	etag string
+}
+
+// Release describes which version each client receives.
+type Release struct {
+	// ID is the position of the release in the history, starting at one. It is set by the
+	// server.
+	ID int `json:"id"`
+
+	// Restores is the id of the release this one restores if it was made by a rollback.
+	Restores int `json:"restores,omitempty"`
+
+	// Default is the version for clients that match no rule.
+	Default int `json:"default"`
+
+	// Rules are checked in order and the first matching rule picks the version.
+	Rules []Rule `json:"rules,omitempty"`
+
+	Comment string    `json:"comment,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// clone returns a deep copy of the release.
+func (r Release) clone() Release {
+	r.Rules = slices.Clone(r.Rules)
+	for i := range r.Rules {
+		r.Rules[i].Match = maps.Clone(r.Rules[i].Match)
+		if p := r.Rules[i].Percent; p != nil {
+			percent := *p
+			r.Rules[i].Percent = &percent
+		}
+	}
+	return r
+}
+
+// Rule selects a version for a subset of clients.
+type Rule struct {
+	// Match lists process annotations that must all have the given values.
+	Match map[string]string `json:"match,omitempty"`
+
+	// Percent is the percentage of matching clients, chosen by a hash of their hostname, that
+	// the rule applies to. Clients keep their place as the percentage grows, so raising a
+	// rollout from 5 to 20 keeps the original 5%. Unset means all matching clients.
+	Percent *float64 `json:"percent,omitempty"`
+
+	Version int `json:"version"`
+}
+
+// Server stores versions and releases and serves configs to clients.
+type Server struct {
+	handler http.Handler
+
+	mu       sync.Mutex
+	versions []*Version
+	history  []Release
+	changed  chan struct{}
+}
+
+// New returns a Server with no versions and no release.
+func New() *Server {
+	s := &Server{changed: make(chan struct{})}
+	s.handler = s.newHandler()
+	return s
+}
+
+// AddVersion stores cfg as a new version and returns its id. It does not change what clients
+// receive until it is part of a release.
+func (s *Server) AddVersion(cfg config.Config, comment string) (int, error) {
+	data, err := cfg.MarshalJSON()
+	if err != nil {
+		return 0, err
+	}
+	sum := sha256.Sum256(data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := len(s.versions) + 1
+	s.versions = append(s.versions, &Version{
+		ID:      id,
+		Created: time.Now(),
+		Comment: comment,
+		Config:  cfg,
+
+		data: data,
+		etag: `"` + strconv.Itoa(id) + "-" + hex.EncodeToString(sum[:8]) + `"`,
+	})
+
+	return id, nil
+}
+
+// Publish stores cfg as a new version and releases it to every client.
+func (s *Server) Publish(cfg config.Config, comment string) (int, error) {
+	id, err := s.AddVersion(cfg, comment)
+	if err != nil {
+		return 0, err
+	}
+	return id, s.SetRelease(Release{Default: id, Comment: comment})
+}
+
+// Version returns the version with the given id.
+func (s *Server) Version(id int) (Version, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v := s.versionLocked(id); v != nil {
+		return *v, true
+	}
+	return Version{}, false
+}
+
+func (s *Server) versionLocked(id int) *Version {
+	if id < 1 || id > len(s.versions) {
+		return nil
+	}
+	return s.versions[id-1]
+}
+
+// Versions returns all of the stored versions, oldest first.
+func (s *Server) Versions() []Version {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Version, len(s.versions))
+	for i, v := range s.versions {
+		out[i] = *v
+	}
+	return out
+}
+
+// SetRelease makes rel the current release. Every version it refers to must exist.
+func (s *Server) SetRelease(rel Release) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.versionLocked(rel.Default) == nil {
+		return errs.Errorf("unknown default version %d", rel.Default)
+	}
+	for i, rule := range rel.Rules {
+		if s.versionLocked(rule.Version) == nil {
+			return errs.Errorf("rule %d: unknown version %d", i, rule.Version)
+		} else if p := rule.Percent; p != nil && (*p < 0 || *p > 100) {
+			return errs.Errorf("rule %d: percent %v out of range", i, *p)
+		}
+	}
+
+	rel.Restores = 0
+	s.appendLocked(rel)
+
+	return nil
+}
+
+// appendLocked makes rel the current release. Must be called with s.mu held.
+func (s *Server) appendLocked(rel Release) {
+	rel.ID = len(s.history) + 1
+	rel.Time = time.Now()
+	s.history = append(s.history, rel.clone())
+	s.notifyLocked()
+}
+
+// Release returns the current release and false if nothing has been released.
+func (s *Server) Release() (Release, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.history) == 0 {
+		return Release{}, false
+	}
+	return s.history[len(s.history)-1].clone(), true
+}
+
+// History returns every release that led to the current one, oldest first.
+func (s *Server) History() []Release {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Release, len(s.history))
+	for i, rel := range s.history {
+		out[i] = rel.clone()
+	}
+	return out
+}
+
+// Rollback makes a new release restoring the one before the current one, keeping the current one
+// in the history. If the current release was made by a rollback, the one before the release it
+// restores is restored, so repeated rollbacks keep going back.
+func (s *Server) Rollback() (Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.history) == 0 {
+		return Release{}, errs.Errorf("no previous release to roll back to")
+	}
+	cur := s.history[len(s.history)-1]
+	id := cur.ID
+	if cur.Restores != 0 {
+		id = cur.Restores
+	}
+	if id < 2 {
+		return Release{}, errs.Errorf("no previous release to roll back to")
+	}
+
+	rel := s.history[id-2].clone()
+	rel.Restores = id - 1
+	rel.Comment = "rollback to release " + strconv.Itoa(id-1)
+	s.appendLocked(rel)
+
+	return s.history[len(s.history)-1].clone(), nil
+}
+
+// Select returns the version a client with the given process annotations receives.
+func (s *Server) Select(annotations map[string]string) (Version, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v := s.selectLocked(annotations); v != nil {
+		return *v, true
+	}
+	return Version{}, false
+}
+
+func (s *Server) selectLocked(annotations map[string]string) *Version {
+	if len(s.history) == 0 {
+		return nil
+	}
+	rel := s.history[len(s.history)-1]
+
+	for _, rule := range rel.Rules {
+		if ruleMatches(rule, annotations) {
+			return s.versionLocked(rule.Version)
+		}
+	}
+	return s.versionLocked(rel.Default)
+}
+
+func ruleMatches(rule Rule, annotations map[string]string) bool {
+	for key, value := range rule.Match {
+		if got, ok := annotations[key]; !ok || got != value {
+			return false
+		}
+	}
+
+	if rule.Percent == nil || *rule.Percent >= 100 {
+		return true
+	} else if *rule.Percent <= 0 {
+		return false
+	}
+
+	hostname, ok := annotations[HostnameKey]
+	if !ok {
+		return false
+	}
+	return float64(rolloutBucket(hostname)) < *rule.Percent*100
+}
+
+// rolloutBucket places a hostname into one of 10000 buckets.
+func rolloutBucket(hostname string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(hostname))
+	return h.Sum64() % 10000
+}
+
+// notifyLocked wakes up any clients waiting for a change. Must be called with s.mu held.
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,171 @@
+package configserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/process"
+	"storj.io/hydrant/submitters"
+)
+
+func TestSelect(t *testing.T) {
+	s := New()
+
+	v1, err := s.Publish(config.Config{Submitter: config.NullSubmitter{}}, "initial")
+	assert.NoError(t, err)
+	v2, err := s.AddVersion(config.Config{Submitter: config.HydratorSubmitter{}}, "canary")
+	assert.NoError(t, err)
+
+	assert.Error(t, s.SetRelease(Release{Default: v1, Rules: []Rule{{Version: 100}}}))
+	assert.NoError(t, s.SetRelease(Release{
+		Default: v1,
+		Rules: []Rule{{
+			Match:   map[string]string{"go.main.path": "storj.io/storj"},
+			Percent: percent(5),
+			Version: v2,
+		}},
+	}))
+
+	// count how many hosts get the canary. it should be about 5%.
+	selected := func(percent float64) map[string]bool {
+		out := make(map[string]bool)
+		for i := range 1000 {
+			host := fmt.Sprintf("host-%d", i)
+			v, ok := s.Select(map[string]string{
+				"go.main.path": "storj.io/storj",
+				HostnameKey:    host,
+			})
+			assert.That(t, ok)
+			if v.ID == v2 {
+				out[host] = true
+			}
+		}
+		return out
+	}
+	canary := selected(5)
+	assert.That(t, len(canary) > 20 && len(canary) < 80)
+
+	// processes that don't match never get the canary.
+	v, ok := s.Select(map[string]string{"go.main.path": "other", HostnameKey: "host-0"})
+	assert.That(t, ok)
+	assert.Equal(t, v.ID, v1)
+
+	// growing the rollout keeps the hosts that already had it.
+	rel, _ := s.Release()
+	rel.Rules[0].Percent = percent(50)
+	assert.NoError(t, s.SetRelease(rel))
+	for host := range canary {
+		assert.That(t, selected(50)[host])
+	}
+
+	// rolling back undoes the growth with a new release, and rolling back again goes further.
+	rel, err = s.Rollback()
+	assert.NoError(t, err)
+	assert.Equal(t, len(selected(5)), len(canary))
+	assert.Equal(t, rel.ID, 4)
+	assert.Equal(t, rel.Restores, 2)
+	assert.Equal(t, len(s.History()), 4)
+
+	rel, err = s.Rollback()
+	assert.NoError(t, err)
+	assert.Equal(t, rel.Restores, 1)
+	assert.Equal(t, len(selected(5)), 0)
+
+	_, err = s.Rollback()
+	assert.Error(t, err)
+	assert.Equal(t, len(s.History()), 5)
+
+	// a zero percent rule applies to no one.
+	assert.NoError(t, s.SetRelease(Release{Default: v1, Rules: []Rule{{Percent: percent(0), Version: v2}}}))
+	assert.Equal(t, len(selected(0)), 0)
+}
+
+func percent(p float64) *float64 { return &p }
+
+func TestRemoteSubmitter(t *testing.T) {
+	s := New()
+	_, err := s.Publish(config.Config{Submitter: config.NullSubmitter{}}, "")
+	assert.NoError(t, err)
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	store := process.NewStore()
+	store.MustRegisterAnnotation(hydrant.String(HostnameKey, "canary"))
+
+	rem := submitters.NewRemoteSubmitterWithOptions(
+		submitters.Environment{Process: store},
+		srv.URL+"/config",
+		&submitters.RemoteOptions{LongPoll: time.Minute},
+	)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go rem.Run(ctx)
+
+	waitSwaps := func(n int) {
+		for len(rem.Status().Swaps) < n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitSwaps(1)
+
+	// release a new version only to the canary host. the long poll picks it up right away.
+	v2, err := s.AddVersion(config.Config{Submitter: config.HydratorSubmitter{}}, "")
+	assert.NoError(t, err)
+	v1, _ := s.Release()
+	assert.NoError(t, s.SetRelease(Release{
+		Default: v1.Default,
+		Rules:   []Rule{{Match: map[string]string{HostnameKey: "canary"}, Version: v2}},
+	}))
+	waitSwaps(2)
+
+	req := httptest.NewRequest("GET", "/tree", nil)
+	rec := httptest.NewRecorder()
+	rem.ServeHTTP(rec, req)
+	assert.That(t, strings.Contains(rec.Body.String(), "HydratorSubmitter"))
+
+	// admin api
+	resp, err := http.Post(srv.URL+"/admin/rollback", "", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	waitSwaps(3)
+}
+
+func TestAdminHandler(t *testing.T) {
+	s := New()
+	_, err := s.Publish(config.Config{Submitter: config.NullSubmitter{}}, "")
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/config", s.ConfigHandler())
+	mux.Handle("/admin/", http.StripPrefix("/admin", submitters.Access{
+		Tokens: map[string]submitters.Role{"secret": submitters.RoleAdmin},
+	}.Handler(s.AdminHandler())))
+
+	code := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// clients fetch configs without authenticating, but the admin API needs the token.
+	assert.Equal(t, code("GET", "/config", ""), http.StatusOK)
+	assert.Equal(t, code("GET", "/admin/release", ""), http.StatusUnauthorized)
+	assert.Equal(t, code("POST", "/admin/rollback", "wrong"), http.StatusUnauthorized)
+	assert.Equal(t, code("GET", "/admin/release", "secret"), http.StatusOK)
+	assert.Equal(t, code("POST", "/admin/rollback", "secret"), http.StatusConflict)
+}
@@ -7,25 +7,28 @@
 //	go run .
 //
 // The config server starts on :9913 and the hydrant client on :9912.
-// Edit the pipeline by POSTing new JSON to the config server:
+// Publish a new pipeline with the config server's admin API:
 //
-//	curl -X POST http://localhost:9913/config -d '{"submitter":{"kind":"null"}}'
+//	curl -X POST http://localhost:9913/admin/publish -d '{"submitter":{"kind":"null"}}'
 //
-// The client picks up the change on its next poll (every 10 seconds) or you
-// can trigger it immediately by visiting http://localhost:9912.
+// and roll it back with:
+//
+//	curl -X POST http://localhost:9913/admin/rollback
+//
+// The client long polls the config server, so the change is picked up as soon
+// as it is posted.
 package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"math/rand/v2"
 	"net/http"
-	"sync"
 	"time"
 
 	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/configserver"
 	"storj.io/hydrant/filter"
 	"storj.io/hydrant/process"
 	"storj.io/hydrant/submitters"
@@ -33,18 +36,23 @@ import (
 
 func main() {
 	// Start the config server. It serves a pipeline config that the
-	// RemoteSubmitter polls periodically.
-	cfgSrv := newConfigServer()
+	// RemoteSubmitter polls.
+	cfgSrv := configserver.New()
+	if _, err := cfgSrv.Publish(initialConfig(), "initial"); err != nil {
+		panic(err)
+	}
 	go func() {
 		panic(http.ListenAndServe(":9913", cfgSrv))
 	}()
 
-	// Create a RemoteSubmitter that polls the config server.
+	// Create a RemoteSubmitter that long polls the config server so that
+	// changes are picked up as soon as they are posted.
 	env := submitters.Environment{
 		Filter:  filter.NewBuiltinEnvionment(),
 		Process: process.DefaultStore,
 	}
-	remote := submitters.NewRemoteSubmitter(env, "http://localhost:9913/config")
+	remote := submitters.NewRemoteSubmitterWithOptions(env, "http://localhost:9913/config",
+		&submitters.RemoteOptions{LongPoll: time.Minute})
 	go remote.Run(context.Background())
 
 	hydrant.SetDefaultSubmitter(remote)
@@ -63,9 +71,10 @@ func main() {
 	fmt.Println("config server at  http://localhost:9913/config")
 	fmt.Println()
 	fmt.Println("try changing the pipeline:")
-	fmt.Println(`  curl http://localhost:9913/config              # see current config`)
-	fmt.Println(`  curl -X POST http://localhost:9913/config \`)
+	fmt.Println(`  curl http://localhost:9913/admin/release       # see current release`)
+	fmt.Println(`  curl -X POST http://localhost:9913/admin/publish \`)
 	fmt.Println(`    -d '{"submitter":{"kind":"null"}}'           # drop all events`)
+	fmt.Println(`  curl -X POST http://localhost:9913/admin/rollback  # undo it`)
 	panic(http.ListenAndServe(":9912", remote))
 }
 
@@ -85,52 +94,13 @@ func doWork(ctx context.Context) {
 	)
 }
 
-// configServer is a trivial HTTP config server. GET returns the current
-// config as JSON. POST replaces it.
-type configServer struct {
-	mu  sync.RWMutex
-	cfg json.RawMessage
-}
-
-func newConfigServer() *configServer {
-	return &configServer{
-		cfg: json.RawMessage(`{
-	"refresh_interval": "10s",
-	"submitter": {
-		"kind": "grouper",
-		"flush_interval": "10s",
-		"group_by": ["name"],
-		"submitter": {"kind": "hydrator"}
-	}
-}`),
-	}
-}
-
-func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		s.mu.RLock()
-		cfg := s.cfg
-		s.mu.RUnlock()
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(cfg)
-
-	case "POST":
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		if !json.Valid(body) {
-			http.Error(w, "invalid json", http.StatusBadRequest)
-			return
-		}
-		s.mu.Lock()
-		s.cfg = body
-		s.mu.Unlock()
-		fmt.Fprintf(w, "config updated\n")
-
-	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+func initialConfig() config.Config {
+	return config.Config{
+		RefreshInterval: 10 * time.Second,
+		Submitter: config.GrouperSubmitter{
+			FlushInterval: 10 * time.Second,
+			GroupBy:       []string{"name"},
+			Submitter:     config.HydratorSubmitter{},
+		},
 	}
 }
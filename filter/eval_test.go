@@ -102,3 +102,34 @@ func BenchmarkEval(b *testing.B) {
 		es.Evaluate(filter, ev)
 	}
 }
+
+func TestExplain(t *testing.T) {
+	env := NewBuiltinEnvionment()
+
+	filter, err := env.Parse(`has(name) && (eq(key(name), foo) || gt(key(dur), 1s))`)
+	assert.NoError(t, err)
+
+	ok, steps := filter.Explain(hydrant.Event{
+		hydrant.String("name", "bar"),
+		hydrant.Duration("dur", 2*time.Second),
+	})
+	assert.True(t, ok)
+	assert.Equal(t, steps, []Step{
+		{Expr: "has(name)", Value: true},
+		{Expr: "key(name)", Value: "bar"},
+		{Expr: "eq(key(name), foo)", Value: false},
+		{Expr: "key(dur)", Value: 2 * time.Second},
+		{Expr: "gt(key(dur), 1s)", Value: true},
+		{Expr: "(eq(key(name), foo) || gt(key(dur), 1s))", Value: true},
+		{Expr: "(has(name) && (eq(key(name), foo) || gt(key(dur), 1s)))", Value: true},
+	})
+
+	// short circuits skip steps and missing keys fail.
+	ok, steps = filter.Explain(hydrant.Event{hydrant.String("name", "bar")})
+	assert.False(t, ok)
+	assert.Equal(t, steps[len(steps)-1], Step{Expr: "key(dur)", Failed: true})
+
+	ok, steps = filter.Explain(hydrant.Event{})
+	assert.False(t, ok)
+	assert.Equal(t, len(steps), 1)
+}
@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/value"
+)
+
+// Step is a sub-expression evaluated while explaining a filter.
+type Step struct {
+	Expr   string `json:"expr"`
+	Value  any    `json:"value,omitempty"`
+	Failed bool   `json:"failed,omitempty"`
+}
+
+// Explain evaluates the filter against the event like EvalState.Evaluate, and also returns every
+// function call and conjunction that was evaluated along with its result, in evaluation order.
+// Sub-expressions skipped by short circuiting are not included. A failed step, like looking up a
+// missing key, ends the evaluation with a false result.
+func (f *Filter) Explain(ev hydrant.Event) (bool, []Step) {
+	es := &EvalState{ev: ev}
+
+	names := make([]string, len(f.env.funcs))
+	for name, n := range f.env.names {
+		names[n] = name
+	}
+
+	var steps []Step
+	var exprs []string // the expressions for the values on the stack
+
+	pushExpr := func(expr string, ok bool) bool {
+		if !ok {
+			steps = append(steps, Step{Expr: expr, Failed: true})
+			return false
+		}
+		v, _ := es.Peek()
+		steps = append(steps, Step{Expr: expr, Value: v.AsAny()})
+		exprs = append(exprs, expr)
+		return true
+	}
+	popExprs := func(n int) []string {
+		n = min(n, len(exprs))
+		args := exprs[len(exprs)-n:]
+		exprs = exprs[:len(exprs)-n]
+		return args
+	}
+
+	for pc := 0; pc < len(f.raw); pc++ {
+		i := f.raw[pc]
+
+		switch i.op {
+		case instPushStr:
+			lit := token(i.arg).literal(f.filter)
+			es.Push(value.String(lit))
+			exprs = append(exprs, lit)
+
+		case instPushVal:
+			if int(i.arg) >= len(f.vals) {
+				return false, steps
+			}
+			es.Push(f.vals[i.arg])
+			exprs = append(exprs, fmt.Sprint(f.vals[i.arg].AsAny()))
+
+		case instCall:
+			if int(i.arg) >= len(f.env.funcs) {
+				return false, steps
+			}
+			expr := names[i.arg] + "(" + strings.Join(popExprs(f.arity[pc]), ", ") + ")"
+			if !pushExpr(expr, f.env.funcs[i.arg](es)) {
+				return false, steps
+			}
+
+		case instAnd, instOr:
+			right, rok := pop(es, value.Value.Bool)
+			left, lok := pop(es, value.Value.Bool)
+			args := popExprs(2)
+			op, res := " && ", left && right
+			if i.op == instOr {
+				op, res = " || ", left || right
+			}
+			es.Push(value.Bool(res))
+			if !pushExpr("("+strings.Join(args, op)+")", lok && rok) {
+				return false, steps
+			}
+
+		case instJumpFalse, instJumpTrue:
+			cond, ok := peek(es, value.Value.Bool)
+			if !ok {
+				return false, steps
+			}
+			if cond == (i.op == instJumpTrue) {
+				pc += int(i.arg)
+			}
+		}
+	}
+
+	res, ok := pop(es, value.Value.Bool)
+	return res && ok, steps
+}
@@ -2,6 +2,7 @@ package filter
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"time"
 
@@ -15,6 +16,11 @@ type Filter struct {
 	filter string
 	prog   []inst
 	vals   []value.Value
+
+	// raw is the program before optimization and arity is the number of arguments of each call
+	// in it, by index. they are used to explain evaluations.
+	raw   []inst
+	arity map[int]int
 }
 
 func (f *Filter) Filter() string {
@@ -103,6 +109,7 @@ func (env *Environment) Parse(filter string) (*Filter, error) {
 		}
 	}
 
+	ps.into.raw = slices.Clone(ps.into.prog)
 	ps.into.prog = optimize(ps.into.prog)
 
 	return ps.into, nil
@@ -317,6 +324,7 @@ func (ps *parseState) parseCallBody(fn uint32) error {
 		return errs.Errorf("expected '(', got %v", tok)
 	}
 
+	args := 0
 	for {
 		if ps.nextIf(tokenRParen) {
 			break
@@ -325,11 +333,16 @@ func (ps *parseState) parseCallBody(fn uint32) error {
 		if err := ps.parseExpr(); err != nil {
 			return err
 		}
+		args++
 
 		ps.nextIf(tokenComma)
 	}
 
-	ps.pushInst(instCall, fn)
+	n := ps.pushInst(instCall, fn)
+	if ps.into.arity == nil {
+		ps.into.arity = make(map[int]int)
+	}
+	ps.into.arity[n] = args
 
 	return nil
 }
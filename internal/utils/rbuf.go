@@ -26,6 +26,11 @@ func NewRingBuffer[T any](size int) *RingBuffer[T] {
 	return rb
 }
 
+// Cap returns the number of items the buffer holds.
+func (r *RingBuffer[T]) Cap() int {
+	return len(r.slots)
+}
+
 // Add adds an item to the buffer, overwriting the oldest item if the buffer is full.
 func (r *RingBuffer[T]) Add(v T) {
 	n := uint64(len(r.slots))
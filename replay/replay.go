@@ -0,0 +1,154 @@
+// Package replay pushes recorded events back through a pipeline. It can test a new config against
+// real traffic, reproduce a bug from the events that caused it, or backfill a hydrator, using the
+// segments written by a file submitter or any other sequence of events.
+package replay
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/submitters"
+	"storj.io/hydrant/value"
+)
+
+// Options configures a replay.
+type Options struct {
+	// RealTime waits between events for the time between their timestamp annotations, so they
+	// arrive with their original gaps. Events are sent as fast as possible otherwise.
+	RealTime bool
+
+	// Speed divides the gaps waited for in real time. It defaults to one.
+	Speed float64
+
+	// ShiftToNow moves every timestamp in the events by the time between the timestamp of the
+	// first event and the start of the replay, so they look like they just happened.
+	ShiftToNow bool
+}
+
+// Report describes a finished replay.
+type Report struct {
+	// Events is the number of events submitted.
+	Events uint64 `json:"events"`
+
+	// Shutdown describes the events lost while shutting down the pipeline.
+	Shutdown submitters.ShutdownReport `json:"shutdown"`
+}
+
+// Replay constructs a pipeline from cfg in env, runs it, submits the events, and shuts it down.
+// It stops at the first error from events or when ctx is canceled, and returns that error after
+// shutting the pipeline down.
+func Replay(
+	ctx context.Context,
+	env submitters.Environment,
+	cfg config.Config,
+	events iter.Seq2[hydrant.Event, error],
+	opts Options,
+) (rep Report, err error) {
+	sub, err := env.New(cfg)
+	if err != nil {
+		return rep, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	var wg sync.WaitGroup
+	wg.Go(func() { sub.Run(runCtx) })
+	defer wg.Wait()
+	defer cancel()
+
+	rep.Events, err = Submit(ctx, sub, events, opts)
+
+	shutCtx, shutCancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer shutCancel()
+	report, shutErr := sub.Shutdown(shutCtx)
+	rep.Shutdown = report
+	if err == nil {
+		err = shutErr
+	}
+	return rep, err
+}
+
+// Submit submits the events to sub as configured by opts and returns how many it submitted. It
+// stops at the first error from events or when ctx is canceled.
+func Submit(
+	ctx context.Context,
+	sub hydrant.Submitter,
+	events iter.Seq2[hydrant.Event, error],
+	opts Options,
+) (n uint64, err error) {
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	var start, first time.Time
+	for ev, err := range events {
+		if err != nil {
+			return n, err
+		}
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		ts, ok := eventTime(ev)
+		if ok && first.IsZero() {
+			start, first = time.Now(), ts
+		}
+
+		if opts.RealTime && ok {
+			wait := time.Duration(float64(ts.Sub(first))/speed) - time.Since(start)
+			if wait > 0 {
+				if timer == nil {
+					timer = time.NewTimer(wait)
+				} else {
+					timer.Reset(wait)
+				}
+				select {
+				case <-ctx.Done():
+					return n, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+
+		if opts.ShiftToNow && !first.IsZero() {
+			ev = shift(ev, start.Sub(first))
+		}
+
+		sub.Submit(ctx, ev)
+		n++
+	}
+	return n, nil
+}
+
+// eventTime returns the timestamp annotation of the event.
+func eventTime(ev hydrant.Event) (time.Time, bool) {
+	for _, a := range ev {
+		if a.Key == "timestamp" {
+			return a.Value.Timestamp()
+		}
+	}
+	return time.Time{}, false
+}
+
+// shift returns a copy of the event with every timestamp moved by d.
+func shift(ev hydrant.Event, d time.Duration) hydrant.Event {
+	ev = slices.Clone(ev)
+	for i, a := range ev {
+		if t, ok := a.Value.Timestamp(); ok {
+			ev[i].Value = value.Timestamp(t.Add(d))
+		}
+	}
+	return ev
+}
@@ -0,0 +1,59 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/submitters"
+)
+
+func TestReplay(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+
+	// record some events 50ms apart.
+	rec, err := submitters.NewFileSubmitter(src, submitters.FileOptions{})
+	assert.NoError(t, err)
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		rec.Submit(t.Context(), hydrant.Event{
+			hydrant.String("name", "op"),
+			hydrant.Int("i", int64(i)),
+			hydrant.Timestamp("timestamp", base.Add(time.Duration(i)*50*time.Millisecond)),
+		})
+	}
+	assert.NoError(t, rec.Flush(t.Context()))
+
+	// replay them in real time into a pipeline that records them again as JSON lines.
+	cfg := config.Config{Submitter: config.FileSubmitter{Dir: dst, Format: submitters.FileJSONL}}
+	start := time.Now()
+	rep, err := Replay(t.Context(), submitters.Environment{}, cfg, submitters.ReadEvents(src), Options{
+		RealTime:   true,
+		ShiftToNow: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, rep.Events, uint64(3))
+	assert.That(t, time.Since(start) >= 100*time.Millisecond)
+
+	var got []hydrant.Event
+	for ev, err := range submitters.ReadEvents(dst) {
+		assert.NoError(t, err)
+		got = append(got, ev)
+	}
+	assert.Equal(t, len(got), 3)
+	for i, ev := range got {
+		assert.Equal(t, ev[0].Value.AsAny(), "op")
+		assert.Equal(t, ev[1].Value.AsAny(), int64(i))
+
+		ts, ok := ev[2].Value.Timestamp()
+		assert.That(t, ok)
+		assert.That(t, ts.After(start.Add(-time.Second)))
+		if i > 0 {
+			prev, _ := got[i-1][2].Value.Timestamp()
+			assert.Equal(t, ts.Sub(prev), 50*time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package submitters
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Role is what an authenticated client of a debug handler is allowed to do.
+type Role int
+
+const (
+	// RoleNone is not allowed to do anything.
+	RoleNone Role = iota
+
+	// RoleRead can view the UI, configs, stats, live streams and stored data.
+	RoleRead
+
+	// RoleAdmin can also change things, like adding taps or starting a shadow pipeline.
+	RoleAdmin
+)
+
+// BasicUser is a user that authenticates with HTTP basic auth.
+type BasicUser struct {
+	Password string
+	Role     Role
+}
+
+// Access describes who may use a debug handler. A client gets the highest role of every method it
+// authenticates with, and Anonymous if it authenticates with none of them.
+type Access struct {
+	// Anonymous is the role of clients that do not authenticate.
+	Anonymous Role
+
+	// Tokens maps bearer tokens sent in the Authorization header to their role.
+	Tokens map[string]Role
+
+	// Users maps basic auth user names to their password and role.
+	Users map[string]BasicUser
+
+	// ClientNames maps the common names and DNS names of verified TLS client certificates to their
+	// role. The server must be configured to request and verify client certificates.
+	ClientNames map[string]Role
+}
+
+// Role returns the role of the client making the request.
+func (a Access) Role(r *http.Request) Role {
+	role := a.Anonymous
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for t, tr := range a.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				role = max(role, tr)
+			}
+		}
+	}
+
+	if name, pass, ok := r.BasicAuth(); ok {
+		if user, ok := a.Users[name]; ok &&
+			subtle.ConstantTimeCompare([]byte(pass), []byte(user.Password)) == 1 {
+			role = max(role, user.Role)
+		}
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		if cr, ok := a.ClientNames[cert.Subject.CommonName]; ok {
+			role = max(role, cr)
+		}
+		for _, name := range cert.DNSNames {
+			if cr, ok := a.ClientNames[name]; ok {
+				role = max(role, cr)
+			}
+		}
+	}
+
+	return role
+}
+
+// Handler wraps h so that only clients with the required role can use it. Requests that change
+// things (anything other than GET, HEAD and OPTIONS, except explaining an event) need RoleAdmin
+// and the rest need RoleRead. Endpoints that change things refuse requests that did not pass
+// through an Access handler.
+func (a Access) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := a.Role(r)
+		if role >= requiredRole(r) {
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, role)))
+			return
+		}
+
+		if role > RoleNone {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if len(a.Users) > 0 {
+			w.Header().Add("WWW-Authenticate", `Basic realm="hydrant"`)
+		}
+		if len(a.Tokens) > 0 {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="hydrant"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+type roleKey struct{}
+
+// requireAdmin returns true if the request was let through by an Access handler as RoleAdmin, and
+// otherwise responds with an error. Starting pipelines from a request must not be possible
+// without access control, so it is refused even when the handler isn't wrapped at all.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if role, ok := r.Context().Value(roleKey{}).(Role); ok && role >= RoleAdmin {
+		return true
+	}
+	http.Error(w, "forbidden: changes require an Access handler granting RoleAdmin", http.StatusForbidden)
+	return false
+}
+
+func requiredRole(r *http.Request) Role {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RoleRead
+	}
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/explain") {
+		return RoleRead
+	}
+	return RoleAdmin
+}
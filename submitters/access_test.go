@@ -0,0 +1,41 @@
+package submitters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestAccess(t *testing.T) {
+	access := Access{
+		Tokens: map[string]Role{"reader": RoleRead},
+		Users:  map[string]BasicUser{"admin": {Password: "hunter2", Role: RoleAdmin}},
+	}
+	h := access.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	code := func(method, path string, auth func(r *http.Request)) int {
+		req := httptest.NewRequest(method, path, nil)
+		if auth != nil {
+			auth(req)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	bearer := func(token string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	basic := func(user, pass string) func(*http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(user, pass) }
+	}
+
+	assert.Equal(t, code("GET", "/config", nil), http.StatusUnauthorized)
+	assert.Equal(t, code("GET", "/config", bearer("wrong")), http.StatusUnauthorized)
+	assert.Equal(t, code("GET", "/config", bearer("reader")), http.StatusOK)
+	assert.Equal(t, code("POST", "/explain", bearer("reader")), http.StatusOK)
+	assert.Equal(t, code("POST", "/taps", bearer("reader")), http.StatusForbidden)
+	assert.Equal(t, code("POST", "/taps", basic("admin", "wrong")), http.StatusUnauthorized)
+	assert.Equal(t, code("POST", "/taps", basic("admin", "hunter2")), http.StatusOK)
+}
@@ -3,10 +3,14 @@ package submitters
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"io/fs"
+	"maps"
 	"net/http"
 	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/zeebo/errs/v2"
 	"github.com/zeebo/hmux"
@@ -22,6 +26,16 @@ type ConfiguredSubmitter struct {
 	root     Submitter
 	named    map[string]*lateSubmitter
 	runnable []runnable
+	state    map[string]statefulSubmitter
+
+	env   Environment
+	tapMu sync.Mutex
+	taps  map[string]*tap
+
+	closed atomic.Bool
+	stats  struct {
+		rejected atomic.Uint64
+	}
 }
 
 type Environment struct {
@@ -30,34 +44,61 @@ type Environment struct {
 }
 
 func (env Environment) New(cfg config.Config) (*ConfiguredSubmitter, error) {
+	return env.NewFrom(cfg, nil)
+}
+
+// NewFrom constructs a ConfiguredSubmitter like New, but stateful submitters (hydrators, trace
+// buffers and prometheus submitters) from prev are reused when a submitter with the same identity
+// has a compatible config. A submitter's identity is its "id" field if set and its position in the
+// config otherwise. The reused submitters are shared with prev, so prev should be stopped shortly
+// after the returned submitter is started. A nil prev is the same as calling New.
+func (env Environment) NewFrom(cfg config.Config, prev *ConfiguredSubmitter) (*ConfiguredSubmitter, error) {
+	return env.newConfigured(cfg, prev, false)
+}
+
+// newConfigured constructs a ConfiguredSubmitter. If shadow is true, outputs are replaced by
+// ShadowSinkSubmitters.
+func (env Environment) newConfigured(cfg config.Config, prev *ConfiguredSubmitter, shadow bool) (*ConfiguredSubmitter, error) {
+	var state map[string]statefulSubmitter
+	if prev != nil {
+		state = prev.state
+	}
+
 	// collect all the names into a late binding submitter
 	named := make(map[string]*lateSubmitter)
 	for name := range cfg.Submitters {
-		named[name] = newLateSubmitter()
+		named[name] = newLateSubmitter(name)
 	}
 
 	// create a constructor with the environment and late bindings and construct all of the
 	// submitters recursively, binding the late submitters as we go.
-	cons := newConstructor(env, named)
+	cons := newConstructor(env, named, state, shadow, cfg.Profile)
 	for name, cfg := range cfg.Submitters {
-		sub, err := cons.Construct(cfg)
+		sub, err := cons.Construct("/submitters/"+name, cfg)
 		if err != nil {
 			return nil, errs.Errorf("constructing submitter %q: %w", name, err)
 		}
 		named[name].SetSubmitter(sub)
 	}
 
+	// the stats report must go to one of the named submitters.
+	if name := cfg.Stats.Submitter; name != "" && named[name] == nil {
+		return nil, errs.Errorf("unknown stats submitter %q", name)
+	}
+
 	// construct the root submitter.
-	root, err := cons.Construct(cfg.Submitter)
+	root, err := cons.Construct("/submitter", cfg.Submitter)
 	if err != nil {
 		return nil, errs.Errorf("constructing root submitter: %w", err)
 	}
 
 	return &ConfiguredSubmitter{
+		env:      env,
 		cfg:      cfg,
 		root:     root,
 		named:    named,
 		runnable: cons.Runnable(),
+		state:    cons.State(),
 	}, nil
 }
 
@@ -71,18 +112,135 @@ func (s *ConfiguredSubmitter) Children() []Submitter {
 
 func (s *ConfiguredSubmitter) ExtraData() any { return nil }
 
+func (s *ConfiguredSubmitter) Stats() []Stat {
+	return []Stat{
+		{"rejected", s.stats.rejected.Load()},
+	}
+}
+
 func (s *ConfiguredSubmitter) Run(ctx context.Context) {
 	var wg sync.WaitGroup
 	for _, rsub := range s.runnable {
 		wg.Go(func() { rsub.Run(ctx) })
 	}
+	if name := s.cfg.Stats.Submitter; name != "" {
+		wg.Go(func() { s.reportStats(ctx, s.named[name], s.cfg.Stats.Interval) })
+	}
 	wg.Wait()
 }
 
 func (s *ConfiguredSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	if s.closed.Load() {
+		s.stats.rejected.Add(1)
+		return
+	}
 	s.root.Submit(ctx, ev)
 }
 
+// Flush flushes the whole pipeline, including named submitters that the root submitter doesn't
+// reach, like the target of the stats report. See Submitter.Flush for details.
+func (s *ConfiguredSubmitter) Flush(ctx context.Context) error {
+	var group errs.Group
+	for _, sub := range s.tops() {
+		if err := ctx.Err(); err != nil {
+			group.Append(err)
+			break
+		}
+		group.Append(sub.Flush(ctx))
+	}
+	return group.Err()
+}
+
+// tops returns the root submitter and the named submitters that are not reached from it or from
+// another named submitter, so that flushing each of them flushes the whole pipeline once.
+func (s *ConfiguredSubmitter) tops() []Submitter {
+	names := slices.Sorted(maps.Keys(s.named))
+
+	// named submitters reached from another submitter are flushed through it.
+	reached := make(map[Submitter]bool)
+	walkSubmitters(reached, s.root)
+	for _, name := range names {
+		for _, child := range s.named[name].Children() {
+			walkSubmitters(reached, child)
+		}
+	}
+
+	// names that alias each other unwrap to the same submitter.
+	tops := []Submitter{s.root}
+	for _, name := range names {
+		if sub := unwrap(s.named[name]); !reached[sub] {
+			reached[sub] = true
+			tops = append(tops, s.named[name])
+		}
+	}
+
+	// named submitters that only reach each other still need to be flushed once.
+	reached = make(map[Submitter]bool)
+	for _, sub := range tops {
+		walkSubmitters(reached, sub)
+	}
+	for _, name := range names {
+		if !reached[unwrap(s.named[name])] {
+			tops = append(tops, s.named[name])
+			walkSubmitters(reached, s.named[name])
+		}
+	}
+	return tops
+}
+
+// ShutdownReport describes the events lost while shutting down a pipeline.
+type ShutdownReport struct {
+	// Rejected is the number of events submitted after the shutdown started.
+	Rejected uint64 `json:"rejected"`
+
+	// Lost is the number of events that exporters failed to deliver or dropped while draining.
+	Lost uint64 `json:"lost"`
+}
+
+// Shutdown stops accepting events and flushes the pipeline. It should be called while Run is
+// still running, and Run should be canceled after it returns. The returned error is from the
+// flush and may be because ctx was canceled before the flush was complete.
+func (s *ConfiguredSubmitter) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	s.closed.Store(true)
+	s.removeTaps()
+
+	tops := s.tops()
+	before := lostEvents(tops...)
+	err := s.Flush(ctx)
+
+	return ShutdownReport{
+		Rejected: s.stats.rejected.Load(),
+		Lost:     lostEvents(tops...) - before,
+	}, err
+}
+
+// lostEvents sums the events lost by every submitter in the trees rooted at subs, counting each
+// submitter once.
+func lostEvents(subs ...Submitter) (lost uint64) {
+	seen := make(map[Submitter]bool)
+	for _, sub := range subs {
+		walkSubmitters(seen, sub)
+	}
+	for sub := range seen {
+		if ls, ok := sub.(interface{ lostEvents() uint64 }); ok {
+			lost += ls.lostEvents()
+		}
+	}
+	return lost
+}
+
+// walkSubmitters adds every submitter in the tree rooted at sub to seen.
+func walkSubmitters(seen map[Submitter]bool, sub Submitter) {
+	sub = unwrap(sub)
+	if seen[sub] {
+		return
+	}
+	seen[sub] = true
+	for _, child := range sub.Children() {
+		walkSubmitters(seen, child)
+	}
+}
+
 //go:embed static
 var static embed.FS
 
@@ -96,20 +254,29 @@ func (s *ConfiguredSubmitter) Handler() http.Handler {
 	for name, sub := range s.named {
 		subs["/"+name] = sub.Handler()
 		names[name] = nameInfo{
-			Kind:  reflect.TypeOf(sub.sub).Elem().Name(),
+			Kind:  reflect.TypeOf(unwrap(sub.sub)).Elem().Name(),
 			Extra: sub.sub.ExtraData(),
 		}
 	}
 
+	// taps and profiles change over time, so the tree is rebuilt for every request.
+	tree := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.tree())
+	})
+
 	return hmux.Dir{
 		// TODO: a bit weird that this is where static is injected, but it's hard to find a way
 		// to do double wildcard merging because we return an http.Handler from this method.
 		"*": http.FileServerFS(func() fs.FS { sub, _ := fs.Sub(static, "static"); return sub }()),
 
-		"/tree":   constJSONHandler(treeify(s)),
-		"/config": constJSONHandler(s.cfg),
-		"/sub":    s.root.Handler(),
-		"/names":  constJSONHandler(names),
-		"/name":   subs,
+		"/tree":    tree,
+		"/config":  constJSONHandler(s.cfg.Redacted()),
+		"/sub":     s.root.Handler(),
+		"/names":   constJSONHandler(names),
+		"/stats":   s.statsHandler(),
+		"/explain": s.explainHandler(),
+		"/taps":    s.tapsHandler(),
+		"/name":    subs,
 	}
 }
@@ -0,0 +1,594 @@
+package submitters
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zeebo/errs/v2"
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/value"
+)
+
+// Console formats.
+const (
+	ConsoleLogfmt = "logfmt"
+	ConsolePretty = "pretty"
+	ConsoleTree   = "tree"
+)
+
+const (
+	// consoleTraceTimeout is how long the tree format waits for the root span of a trace before
+	// printing what it has.
+	consoleTraceTimeout = 30 * time.Second
+	maxConsoleTraces    = 1000
+	maxConsoleSpans     = 1000
+	maxConsoleNameWidth = 32
+)
+
+var (
+	// consoleFirstKeys are printed before the other annotations, in this order.
+	consoleFirstKeys = []string{"name", "message", "duration"}
+
+	// consoleHiddenKeys are only printed when verbose.
+	consoleHiddenKeys = []string{"file", "func", "line", "start", "trace_id", "span_id", "parent_id"}
+)
+
+// ANSI escapes used when colors are enabled.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiCyan   = "\x1b[36m"
+	ansiYellow = "\x1b[33m"
+)
+
+// ConsoleSubmitter writes events to a terminal for local development. The logfmt format writes a
+// line of key=value pairs per event, the pretty format writes aligned and colored lines, and the
+// tree format buffers the spans of each trace and writes them indented under their parents once
+// the root span completes. The name, message and duration annotations are written first, and
+// noisy annotations like file and line only when verbose.
+type ConsoleSubmitter struct {
+	w       io.Writer
+	format  string
+	color   bool
+	verbose bool
+	live    liveBuffer
+
+	stats struct {
+		received     atomic.Uint64
+		written      atomic.Uint64
+		writeErrors  atomic.Uint64
+		traces       atomic.Uint64
+		tracesPartly atomic.Uint64
+	}
+
+	wait      lockTimer
+	mu        sync.Mutex
+	buf       []byte
+	nameWidth int
+	pending   map[[16]byte]*consoleTrace
+	order     [][16]byte // pending trace ids by arrival, possibly including written ones
+
+	written    map[[16]byte]struct{} // ids of recently written traces
+	writtenIDs [][16]byte            // ring of the ids in written
+	writtenPos int
+}
+
+type consoleTrace struct {
+	events []hydrant.Event
+	first  time.Time
+}
+
+// NewConsoleSubmitter returns a ConsoleSubmitter writing to w in the format, one of logfmt,
+// pretty or tree. Colors are written if color is set.
+func NewConsoleSubmitter(w io.Writer, format string, color, verbose bool) (*ConsoleSubmitter, error) {
+	switch format {
+	case "":
+		format = ConsolePretty
+	case ConsoleLogfmt, ConsolePretty, ConsoleTree:
+	default:
+		return nil, errs.Errorf("unknown console format %q", format)
+	}
+	return &ConsoleSubmitter{
+		w:       w,
+		format:  format,
+		color:   color,
+		verbose: verbose,
+		live:    newLiveBuffer(),
+		pending: make(map[[16]byte]*consoleTrace),
+
+		written:    make(map[[16]byte]struct{}),
+		writtenIDs: make([][16]byte, maxConsoleTraces),
+	}, nil
+}
+
+// isTerminal returns true if w is a terminal and the NO_COLOR environment variable is not set.
+func isTerminal(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *ConsoleSubmitter) Children() []Submitter {
+	return []Submitter{}
+}
+
+func (c *ConsoleSubmitter) ExtraData() any {
+	return map[string]string{
+		"format":  c.format,
+		"color":   strconv.FormatBool(c.color),
+		"verbose": strconv.FormatBool(c.verbose),
+	}
+}
+
+func (c *ConsoleSubmitter) lockWait() *lockTimer { return &c.wait }
+
+func (c *ConsoleSubmitter) Run(ctx context.Context) {
+	if c.format != ConsoleTree {
+		return
+	}
+
+	ticker := time.NewTicker(consoleTraceTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.writeAll()
+			return
+		case now := <-ticker.C:
+			c.expire(now)
+		}
+	}
+}
+
+// Flush writes the traces still waiting for their root span.
+func (c *ConsoleSubmitter) Flush(ctx context.Context) error {
+	c.writeAll()
+	return nil
+}
+
+func (c *ConsoleSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	c.live.Record(ev)
+	c.stats.received.Add(1)
+
+	c.wait.lock(&c.mu)
+	defer c.mu.Unlock()
+
+	traceID, ok := eventTraceID(ev)
+	if c.format != ConsoleTree || !ok {
+		c.buf = c.appendLine(c.buf[:0], ev)
+		c.write()
+		return
+	}
+
+	// spans of a trace that was already written are written on their own line.
+	if _, ok := c.written[traceID]; ok {
+		c.buf = c.appendLine(c.buf[:0], ev)
+		c.write()
+		return
+	}
+
+	tr := c.pending[traceID]
+	if tr == nil {
+		if len(c.pending) >= maxConsoleTraces {
+			c.writeOldest()
+		}
+		tr = &consoleTrace{first: time.Now()}
+		c.pending[traceID] = tr
+		c.order = append(c.order, traceID)
+		c.compactOrder()
+	}
+	if len(tr.events) < maxConsoleSpans {
+		tr.events = append(tr.events, ev)
+	}
+
+	if isRootSpan(ev) {
+		c.writeTrace(traceID, tr, true)
+	}
+}
+
+// write writes the buffer. Must be called with c.mu held.
+func (c *ConsoleSubmitter) write() {
+	if _, err := c.w.Write(c.buf); err != nil {
+		c.stats.writeErrors.Add(1)
+		return
+	}
+	c.stats.written.Add(1)
+}
+
+// writeTrace writes the trace as a tree and remembers that it was written. Must be called with
+// c.mu held.
+func (c *ConsoleSubmitter) writeTrace(traceID [16]byte, tr *consoleTrace, complete bool) {
+	delete(c.pending, traceID)
+
+	if old := c.writtenIDs[c.writtenPos]; old != ([16]byte{}) {
+		delete(c.written, old)
+	}
+	c.writtenIDs[c.writtenPos] = traceID
+	c.writtenPos = (c.writtenPos + 1) % len(c.writtenIDs)
+	c.written[traceID] = struct{}{}
+
+	if complete {
+		c.stats.traces.Add(1)
+	} else {
+		c.stats.tracesPartly.Add(1)
+	}
+	c.buf = c.appendTree(c.buf[:0], traceID, tr.events, complete)
+	c.write()
+}
+
+// compactOrder removes the ids of written traces from order once they outnumber the pending
+// ones. Must be called with c.mu held.
+func (c *ConsoleSubmitter) compactOrder() {
+	if len(c.order) <= 2*len(c.pending) {
+		return
+	}
+	c.order = slices.DeleteFunc(c.order, func(id [16]byte) bool {
+		_, ok := c.pending[id]
+		return !ok
+	})
+}
+
+// writeOldest writes the oldest pending trace. Must be called with c.mu held.
+func (c *ConsoleSubmitter) writeOldest() {
+	for len(c.order) > 0 {
+		id := c.order[0]
+		c.order = c.order[1:]
+		if tr, ok := c.pending[id]; ok {
+			c.writeTrace(id, tr, false)
+			return
+		}
+	}
+}
+
+// expire writes the traces that have waited longer than the timeout for their root span.
+func (c *ConsoleSubmitter) expire(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.order) > 0 {
+		id := c.order[0]
+		tr, ok := c.pending[id]
+		if ok && now.Sub(tr.first) < consoleTraceTimeout {
+			break
+		}
+		c.order = c.order[1:]
+		if ok {
+			c.writeTrace(id, tr, false)
+		}
+	}
+}
+
+// writeAll writes every pending trace.
+func (c *ConsoleSubmitter) writeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range c.order {
+		if tr, ok := c.pending[id]; ok {
+			c.writeTrace(id, tr, false)
+		}
+	}
+	c.order = nil
+}
+
+// ordered returns the annotations to write in the order to write them: the first keys, then the
+// rest in their original order, without the hidden keys unless verbose.
+func (c *ConsoleSubmitter) ordered(ev hydrant.Event) []hydrant.Annotation {
+	out := make([]hydrant.Annotation, 0, len(ev))
+	for _, key := range consoleFirstKeys {
+		if i := slices.IndexFunc(ev, func(a hydrant.Annotation) bool { return a.Key == key }); i >= 0 {
+			out = append(out, ev[i])
+		}
+	}
+	for _, a := range ev {
+		if slices.Contains(consoleFirstKeys, a.Key) {
+			continue
+		}
+		if !c.verbose && slices.Contains(consoleHiddenKeys, a.Key) {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// appendLine appends the event as a line in the logfmt or pretty format.
+func (c *ConsoleSubmitter) appendLine(buf []byte, ev hydrant.Event) []byte {
+	anns := c.ordered(ev)
+
+	if c.format == ConsoleLogfmt {
+		for i, a := range anns {
+			if i > 0 {
+				buf = append(buf, ' ')
+			}
+			buf = c.appendKeyValue(buf, a)
+		}
+		return append(buf, '\n')
+	}
+
+	var name, message string
+	rest := anns[:0:0]
+	for _, a := range anns {
+		switch a.Key {
+		case "name":
+			name = consoleText(consoleValue(a.Value))
+		case "message":
+			message = consoleText(consoleValue(a.Value))
+		case "timestamp", "success":
+		default:
+			rest = append(rest, a)
+		}
+	}
+
+	buf = c.appendTime(buf, ev)
+	buf = append(buf, ' ')
+	buf = c.appendStatus(buf, ev)
+	buf = append(buf, ' ')
+
+	if name != "" || c.nameWidth > 0 {
+		if n := min(utf8.RuneCountInString(name), maxConsoleNameWidth); n > c.nameWidth {
+			c.nameWidth = n
+		}
+		buf = c.paint(buf, ansiBold, name)
+		for n := utf8.RuneCountInString(name); n < c.nameWidth; n++ {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, ' ')
+	}
+	if message != "" {
+		buf = append(buf, message...)
+		buf = append(buf, ' ')
+	}
+	for _, a := range rest {
+		buf = c.appendKeyValue(buf, a)
+		buf = append(buf, ' ')
+	}
+
+	buf = buf[:len(buf)-1]
+	return append(buf, '\n')
+}
+
+// appendTree appends the events of the trace with every span indented under its parent and every
+// log under its span.
+func (c *ConsoleSubmitter) appendTree(buf []byte, traceID [16]byte, events []hydrant.Event, complete bool) []byte {
+	type node struct {
+		ev       hydrant.Event
+		children []*node
+	}
+
+	spans := make(map[[8]byte]*node)
+	var nodes []*node
+	for _, ev := range events {
+		n := &node{ev: ev}
+		nodes = append(nodes, n)
+		if id, ok := annotationSpanID(ev, "span_id"); ok && isSpan(ev) {
+			spans[id] = n
+		}
+	}
+
+	var roots []*node
+	for _, n := range nodes {
+		var parent *node
+		if isSpan(n.ev) {
+			if id, ok := annotationSpanID(n.ev, "parent_id"); ok && !isRootSpan(n.ev) {
+				parent = spans[id]
+			}
+		} else if id, ok := annotationSpanID(n.ev, "span_id"); ok {
+			parent = spans[id]
+		}
+		if parent != nil && parent != n {
+			parent.children = append(parent.children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+
+	start := func(n *node) time.Time {
+		for _, a := range n.ev {
+			if a.Key == "start" || (a.Key == "timestamp" && !isSpan(n.ev)) {
+				t, _ := a.Value.Timestamp()
+				return t
+			}
+		}
+		return time.Time{}
+	}
+	sortNodes := func(ns []*node) {
+		slices.SortStableFunc(ns, func(a, b *node) int { return start(a).Compare(start(b)) })
+	}
+
+	buf = c.appendTime(buf, events[0])
+	buf = append(buf, ' ')
+	buf = c.paint(buf, ansiDim, "trace "+hex.EncodeToString(traceID[:8]))
+	if !complete {
+		buf = append(buf, ' ')
+		buf = c.paint(buf, ansiYellow, "(incomplete)")
+	}
+	buf = append(buf, '\n')
+
+	var walk func(ns []*node, depth int)
+	walk = func(ns []*node, depth int) {
+		sortNodes(ns)
+		for _, n := range ns {
+			for range depth + 1 {
+				buf = append(buf, "  "...)
+			}
+			buf = c.appendStatus(buf, n.ev)
+			for _, a := range c.ordered(n.ev) {
+				if a.Key == "timestamp" || a.Key == "success" {
+					continue
+				}
+				buf = append(buf, ' ')
+				switch a.Key {
+				case "name":
+					buf = c.paint(buf, ansiBold, consoleText(consoleValue(a.Value)))
+				case "message":
+					buf = append(buf, consoleText(consoleValue(a.Value))...)
+				default:
+					buf = c.appendKeyValue(buf, a)
+				}
+			}
+			buf = append(buf, '\n')
+			walk(n.children, depth+1)
+		}
+	}
+	walk(roots, 0)
+
+	return buf
+}
+
+// appendTime appends the time of the event's timestamp, or now if it has none.
+func (c *ConsoleSubmitter) appendTime(buf []byte, ev hydrant.Event) []byte {
+	t := time.Now()
+	for _, a := range ev {
+		if a.Key == "timestamp" {
+			if ts, ok := a.Value.Timestamp(); ok {
+				t = ts
+			}
+		}
+	}
+	return c.paint(buf, ansiDim, t.Local().Format("15:04:05.000"))
+}
+
+// appendStatus appends a mark for the success of a span, or a dot for other events.
+func (c *ConsoleSubmitter) appendStatus(buf []byte, ev hydrant.Event) []byte {
+	for _, a := range ev {
+		if a.Key != "success" {
+			continue
+		}
+		if ok, _ := a.Value.Bool(); ok {
+			return c.paint(buf, ansiGreen, "✓")
+		}
+		return c.paint(buf, ansiRed, "✗")
+	}
+	return c.paint(buf, ansiDim, "·")
+}
+
+func (c *ConsoleSubmitter) appendKeyValue(buf []byte, a hydrant.Annotation) []byte {
+	buf = c.paint(buf, ansiCyan, a.Key)
+	buf = append(buf, '=')
+	s := consoleValue(a.Value)
+	if s == "" || strings.ContainsAny(s, " \"=") || !consolePrintable(s) {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+// consoleText returns s quoted if it has control characters, so a message or name can not break
+// the line or write escape sequences to the terminal.
+func consoleText(s string) string {
+	if consolePrintable(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// consolePrintable reports whether s is valid UTF-8 with only printable characters.
+func consolePrintable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// paint appends s in the color if colors are enabled.
+func (c *ConsoleSubmitter) paint(buf []byte, color, s string) []byte {
+	if !c.color {
+		return append(buf, s...)
+	}
+	buf = append(buf, color...)
+	buf = append(buf, s...)
+	return append(buf, ansiReset...)
+}
+
+// consoleValue formats the value for the console.
+func consoleValue(v value.Value) string {
+	switch v.Kind() {
+	case value.KindBytes:
+		b, _ := v.Bytes()
+		return hex.EncodeToString(b)
+	case value.KindTimestamp:
+		t, _ := v.Timestamp()
+		return t.Format(time.RFC3339Nano)
+	case value.KindFloat:
+		f, _ := v.Float()
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case value.KindHistogram:
+		s := hydrant.Annotation{Value: v}.String()
+		return strings.TrimPrefix(s, "=")
+	case value.KindEmpty:
+		return ""
+	}
+	return valueString(v)
+}
+
+// isSpan returns true if the event is a span, which has a parent_id.
+func isSpan(ev hydrant.Event) bool {
+	_, ok := annotationSpanID(ev, "parent_id")
+	return ok
+}
+
+// isRootSpan returns true if the event is the root span of its trace, whose parent is itself.
+func isRootSpan(ev hydrant.Event) bool {
+	span, ok := annotationSpanID(ev, "span_id")
+	parent, pok := annotationSpanID(ev, "parent_id")
+	return ok && pok && span == parent
+}
+
+func annotationSpanID(ev hydrant.Event, key string) ([8]byte, bool) {
+	for _, a := range ev {
+		if a.Key == key {
+			return a.Value.SpanId()
+		}
+	}
+	return [8]byte{}, false
+}
+
+func (c *ConsoleSubmitter) Stats() []Stat {
+	c.mu.Lock()
+	pending := uint64(len(c.pending))
+	c.mu.Unlock()
+	return []Stat{
+		{"received", c.stats.received.Load()},
+		{"written", c.stats.written.Load()},
+		{"write_errors", c.stats.writeErrors.Load()},
+		{"traces", c.stats.traces.Load()},
+		{"traces_incomplete", c.stats.tracesPartly.Load()},
+		{"pending_traces", pending},
+	}
+}
+
+func (c *ConsoleSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(c)),
+		"/live":  c.live.Handler(),
+		"/stats": statsHandler(c.Stats),
+	}
+}
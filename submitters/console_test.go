@@ -0,0 +1,118 @@
+package submitters
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+)
+
+func TestConsole(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	log := hydrant.Event{
+		hydrant.String("file", "main.go"),
+		hydrant.Int("line", 10),
+		hydrant.String("message", "hello world"),
+		hydrant.Timestamp("timestamp", ts),
+		hydrant.Int("count", 3),
+	}
+
+	var buf bytes.Buffer
+	cs, err := NewConsoleSubmitter(&buf, ConsoleLogfmt, false, false)
+	assert.NoError(t, err)
+	cs.Submit(t.Context(), log)
+	assert.Equal(t, buf.String(),
+		`message="hello world" timestamp=2026-01-02T03:04:05`+ts.Format("Z07:00")+` count=3`+"\n")
+
+	buf.Reset()
+	cs, err = NewConsoleSubmitter(&buf, ConsoleLogfmt, false, true)
+	assert.NoError(t, err)
+	cs.Submit(t.Context(), log)
+	assert.That(t, strings.Contains(buf.String(), "file=main.go line=10"))
+
+	span := func(name string, id, parent byte, dur time.Duration, ok bool) hydrant.Event {
+		return hydrant.Event{
+			hydrant.String("name", name),
+			hydrant.Timestamp("start", ts.Add(time.Duration(id)*time.Millisecond)),
+			hydrant.SpanId("span_id", [8]byte{id}),
+			hydrant.SpanId("parent_id", [8]byte{parent}),
+			hydrant.TraceId("trace_id", [16]byte{1}),
+			hydrant.Timestamp("timestamp", ts),
+			hydrant.Duration("duration", dur),
+			hydrant.Bool("success", ok),
+		}
+	}
+
+	buf.Reset()
+	cs, err = NewConsoleSubmitter(&buf, ConsolePretty, false, false)
+	assert.NoError(t, err)
+	cs.Submit(t.Context(), span("request", 1, 1, time.Second, true))
+	cs.Submit(t.Context(), log)
+	assert.Equal(t, buf.String(), ""+
+		"03:04:05.000 ✓ request duration=1s\n"+
+		"03:04:05.000 ·         hello world count=3\n")
+
+	buf.Reset()
+	cs, err = NewConsoleSubmitter(&buf, ConsoleTree, false, false)
+	assert.NoError(t, err)
+	cs.Submit(t.Context(), span("query", 3, 2, time.Millisecond, false))
+	cs.Submit(t.Context(), append(slices.Clone(log), hydrant.SpanId("span_id", [8]byte{2}), hydrant.TraceId("trace_id", [16]byte{1})))
+	cs.Submit(t.Context(), span("handler", 2, 1, 2*time.Millisecond, true))
+	assert.Equal(t, buf.String(), "")
+	cs.Submit(t.Context(), span("request", 1, 1, 3*time.Millisecond, true))
+	assert.Equal(t, buf.String(), ""+
+		"03:04:05.000 trace 0100000000000000\n"+
+		"  ✓ request duration=3ms\n"+
+		"    ✓ handler duration=2ms\n"+
+		"      · hello world count=3\n"+
+		"      ✗ query duration=1ms\n")
+
+	// spans that arrive after their trace was written are written on their own line.
+	buf.Reset()
+	cs.Submit(t.Context(), span("late", 6, 1, time.Millisecond, true))
+	assert.NoError(t, cs.Flush(t.Context()))
+	assert.Equal(t, buf.String(), "03:04:05.000 ✓ late duration=1ms\n")
+
+	// traces without a root span are written when flushed.
+	buf.Reset()
+	orphan := span("orphan", 5, 4, time.Millisecond, true)
+	orphan[4] = hydrant.TraceId("trace_id", [16]byte{2})
+	cs.Submit(t.Context(), orphan)
+	assert.NoError(t, cs.Flush(t.Context()))
+	assert.That(t, strings.Contains(buf.String(), "(incomplete)"))
+	assert.That(t, strings.Contains(buf.String(), "orphan"))
+
+	// written traces don't pile up behind one that is still waiting for its root.
+	orphan[4] = hydrant.TraceId("trace_id", [16]byte{4})
+	cs.Submit(t.Context(), orphan)
+	for i := range 100 {
+		root := span("request", 1, 1, time.Millisecond, true)
+		root[4] = hydrant.TraceId("trace_id", [16]byte{3, byte(i)})
+		cs.Submit(t.Context(), root)
+	}
+	assert.Equal(t, len(cs.pending), 1)
+	assert.That(t, len(cs.order) <= 3)
+
+	// control characters in messages and names are escaped.
+	buf.Reset()
+	cs, err = NewConsoleSubmitter(&buf, ConsolePretty, false, false)
+	assert.NoError(t, err)
+	cs.Submit(t.Context(), hydrant.Event{
+		hydrant.String("name", "a\x1b[2J"),
+		hydrant.String("message", "two\nlines"),
+		hydrant.Timestamp("timestamp", ts),
+		hydrant.String("k", "\x1b"),
+	})
+	assert.Equal(t, buf.String(), `03:04:05.000 · "a\x1b[2J" "two\nlines" k="\x1b"`+"\n")
+
+	buf.Reset()
+	cs, err = NewConsoleSubmitter(&buf, ConsoleTree, false, false)
+	assert.NoError(t, err)
+	cs.Submit(t.Context(), span("bad\rname", 1, 1, time.Millisecond, true))
+	assert.That(t, strings.Contains(buf.String(), `✓ "bad\rname" duration=1ms`))
+}
@@ -5,11 +5,16 @@ import (
 	"encoding/json/jsontext"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/zeebo/assert"
 
+	"github.com/histdb/histdb/flathist"
+	"storj.io/hydrant"
 	"storj.io/hydrant/config"
 	"storj.io/hydrant/filter"
 	"storj.io/hydrant/process"
@@ -40,6 +45,259 @@ func TestConstruct(t *testing.T) {
 	fmt.Println(string(data))
 }
 
+func TestConstructFromPreservesState(t *testing.T) {
+	env := Environment{
+		Filter:  filter.NewBuiltinEnvionment(),
+		Process: process.DefaultStore,
+	}
+
+	parse := func(data string) (cfg config.Config) {
+		assert.NoError(t, json.Unmarshal([]byte(data), &cfg))
+		return cfg
+	}
+
+	prev, err := env.New(parse(`{
+		"submitter": {
+			"kind": "filter",
+			"filter": "has(name)",
+			"submitter": [{"kind": "hydrator", "id": "hyd"}, {"kind": "prometheus"}]
+		}
+	}`))
+	assert.NoError(t, err)
+
+	prev.Submit(t.Context(), hydrant.Event{hydrant.String("name", "foo")})
+
+	// changing the filter keeps the hydrator by id and the prometheus submitter by position.
+	next, err := env.NewFrom(parse(`{
+		"submitter": {
+			"kind": "filter",
+			"filter": "has(name) && has(span_id)",
+			"submitter": [{"kind": "hydrator", "id": "hyd"}, {"kind": "prometheus"}]
+		}
+	}`), prev)
+	assert.NoError(t, err)
+	assert.Equal(t, prev.state["id:hyd"].sub, next.state["id:hyd"].sub)
+	assert.Equal(t, prev.state["/submitter/submitter/1"].sub, next.state["/submitter/submitter/1"].sub)
+
+	count := 0
+	assert.NoError(t, next.state["id:hyd"].sub.(*HydratorSubmitter).Query([]byte(`name=foo`),
+		func(name []byte, hist *flathist.Histogram) bool {
+			count++
+			return true
+		}))
+	assert.Equal(t, count, 1)
+
+	// incompatible configs and moved submitters without ids get fresh state.
+	next, err = env.NewFrom(parse(`{
+		"submitter": [{"kind": "prometheus", "namespace": "other"}, {"kind": "hydrator", "id": "hyd"}]
+	}`), next)
+	assert.NoError(t, err)
+	assert.Equal(t, prev.state["id:hyd"].sub, next.state["id:hyd"].sub)
+	assert.NotEqual(t, prev.state["/submitter/submitter/1"].sub, next.state["/submitter/0"].sub)
+
+	// ids must be unique.
+	_, err = env.New(parse(`{
+		"submitter": [{"kind": "hydrator", "id": "hyd"}, {"kind": "hydrator", "id": "hyd"}]
+	}`))
+	assert.Error(t, err)
+}
+
+func TestShutdown(t *testing.T) {
+	var requests atomic.Int64
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"submitter": {
+			"kind": "grouper",
+			"flush_interval": "1h",
+			"group_by": ["name"],
+			"submitter": {"kind": "http", "endpoint": "`+srv.URL+`", "max_batch_size": 10}
+		}
+	}`), &cfg))
+
+	sub, err := Environment{Process: process.DefaultStore}.New(cfg)
+	assert.NoError(t, err)
+
+	// flushing pushes the groups through the exporter without waiting for the interval.
+	sub.Submit(t.Context(), hydrant.Event{hydrant.String("name", "a")})
+	sub.Submit(t.Context(), hydrant.Event{hydrant.String("name", "b")})
+	assert.NoError(t, sub.Flush(t.Context()))
+	assert.Equal(t, requests.Load(), int64(1))
+
+	// events that fail to export while draining are reported as lost, and events after the
+	// shutdown are rejected.
+	fail.Store(true)
+	sub.Submit(t.Context(), hydrant.Event{hydrant.String("name", "a")})
+	rep, err := sub.Shutdown(t.Context())
+	assert.Error(t, err)
+	assert.Equal(t, rep.Lost, uint64(1))
+
+	sub.Submit(t.Context(), hydrant.Event{hydrant.String("name", "a")})
+	rep, err = sub.Shutdown(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, rep, ShutdownReport{Rejected: 1})
+}
+
+func TestFlushNamed(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer srv.Close()
+
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"submitter": {"kind": "null"},
+		"submitters": {
+			"stats": "export",
+			"export": {"kind": "http", "endpoint": "`+srv.URL+`", "max_batch_size": 10}
+		},
+		"stats": {"submitter": "stats"}
+	}`), &cfg))
+
+	sub, err := Environment{Process: process.DefaultStore}.New(cfg)
+	assert.NoError(t, err)
+
+	// the named submitters aren't reached from the root, and since "stats" is another name for
+	// "export" they are flushed once.
+	tops := sub.tops()
+	assert.Equal(t, len(tops), 2)
+	assert.Equal(t, unwrap(tops[1]), unwrap(sub.named["stats"]))
+
+	sub.submitStats(t.Context(), sub.named["stats"])
+	assert.NoError(t, sub.Flush(t.Context()))
+	assert.Equal(t, requests.Load(), int64(1))
+}
+
+func TestPipelineStats(t *testing.T) {
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"submitter": {"kind": "filter", "filter": "has(keep)", "submitter": "self"},
+		"submitters": {"self": {"kind": "trace_buffer"}},
+		"stats": {"submitter": "self"}
+	}`), &cfg))
+
+	sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.NoError(t, err)
+
+	sub.Submit(t.Context(), hydrant.Event{hydrant.String("name", "drop")})
+
+	rec := httptest.NewRecorder()
+	sub.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/stats", nil))
+	var stats map[string]pathStats
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, stats["/sub"].Kind, "FilterSubmitter")
+	assert.Equal(t, stats["/sub"].Stats[2], Stat{"filtered", 1})
+	assert.Equal(t, stats["/name/self"].Kind, "TraceBufferSubmitter")
+
+	rec = httptest.NewRecorder()
+	sub.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/stats/metrics", nil))
+	assert.That(t, strings.Contains(rec.Body.String(),
+		`hydrant_submitter_stat{path="/sub",kind="FilterSubmitter",stat="filtered"} 1`))
+
+	// the stats events go to the named submitter.
+	self := sub.named["self"].sub
+	before := self.Stats()[0].Value
+	sub.submitStats(t.Context(), self)
+	assert.Equal(t, self.Stats()[0].Value-before, uint64(len(stats)))
+
+	// the stats submitter must exist.
+	cfg.Stats.Submitter = "missing"
+	_, err = Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.Error(t, err)
+}
+
+func TestProfile(t *testing.T) {
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"submitter": {"kind": "filter", "filter": "has(name)", "submitter": "hyd"},
+		"submitters": {"hyd": {"kind": "hydrator"}},
+		"profile": true
+	}`), &cfg))
+
+	sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.NoError(t, err)
+
+	sub.Submit(t.Context(), hydrant.Event{hydrant.String("name", "a")})
+	sub.Submit(t.Context(), hydrant.Event{hydrant.String("other", "b")})
+
+	rec := httptest.NewRecorder()
+	sub.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/tree", nil))
+
+	type node struct {
+		Kind    string                    `json:"kind"`
+		Sub     []node                    `json:"sub"`
+		Profile map[string]latencySummary `json:"profile"`
+	}
+	var tree node
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tree))
+
+	fil := tree.Sub[0]
+	assert.Equal(t, fil.Kind, "FilterSubmitter")
+	assert.Equal(t, fil.Profile["submit"].Count, uint64(2))
+
+	hyd := fil.Sub[0]
+	assert.Equal(t, hyd.Kind, "HydratorSubmitter")
+	assert.Equal(t, hyd.Profile["submit"].Count, uint64(1))
+	assert.Equal(t, hyd.Profile["lock_wait"].Count, uint64(1))
+}
+
+func TestExplain(t *testing.T) {
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"submitter": [
+			{"kind": "filter", "filter": "gt(key(duration), 1s)", "submitter": {
+				"kind": "grouper", "group_by": ["name"], "submitter": ["hyd", {"kind": "prometheus"}]
+			}},
+			{"kind": "filter", "filter": "eq(key(name), other)", "submitter": {"kind": "null"}}
+		],
+		"submitters": {"hyd": {"kind": "hydrator"}}
+	}`), &cfg))
+
+	sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	sub.Handler().ServeHTTP(rec, httptest.NewRequest("POST", "/explain",
+		strings.NewReader(`{"name": "foo", "duration": "2s"}`)))
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	var resp struct {
+		Explain Explanation `json:"explain"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	passed := resp.Explain.Sub[0]
+	assert.Equal(t, passed.Path, "/sub/sub/0")
+	assert.That(t, *passed.Passed)
+	assert.Equal(t, passed.Steps[len(passed.Steps)-1].Expr, "gt(key(duration), 1s)")
+
+	grouper := passed.Sub[0]
+	assert.Equal(t, grouper.Kind, "GrouperSubmitter")
+	assert.That(t, grouper.NewGroup)
+
+	// the children see the aggregated event with the histograms.
+	multi := grouper.Sub[0]
+	assert.Equal(t, multi.Sub[0].Name, "hyd")
+	assert.That(t, multi.Sub[0].Reached)
+	assert.Equal(t, multi.Sub[1].Kind, "PrometheusSubmitter")
+	assert.That(t, multi.Sub[1].Reached)
+
+	assert.That(t, !*resp.Explain.Sub[1].Passed)
+	assert.Equal(t, len(resp.Explain.Sub[1].Sub), 0)
+
+	// explaining doesn't submit the event.
+	assert.Equal(t, sub.named["hyd"].Stats()[0].Value, uint64(0))
+}
+
 var exampleData = []byte(`{
 	"refresh_interval": "10m0s",
 	"submitter": "default",
@@ -1,21 +1,46 @@
 package submitters
 
 import (
+	"os"
+	"slices"
+	"strconv"
+
 	"github.com/zeebo/errs/v2"
 
 	"storj.io/hydrant/config"
 )
 
+// statefulSubmitter is a submitter that holds on to data that should survive a config swap along
+// with the config that it was constructed from.
+type statefulSubmitter struct {
+	cfg config.Submitter
+	sub Submitter
+}
+
 type constructor struct {
 	env      Environment
 	named    map[string]*lateSubmitter
 	runnable []runnable
+	prev     map[string]statefulSubmitter
+	state    map[string]statefulSubmitter
+	shadow   bool
+	profile  bool
 }
 
-func newConstructor(env Environment, named map[string]*lateSubmitter) *constructor {
+func newConstructor(
+	env Environment,
+	named map[string]*lateSubmitter,
+	prev map[string]statefulSubmitter,
+	shadow bool,
+	profile bool,
+) *constructor {
 	return &constructor{
-		env:   env,
-		named: named,
+		env:     env,
+		named:   named,
+		prev:    prev,
+		state:   make(map[string]statefulSubmitter),
+		shadow:  shadow,
+		profile: profile,
 	}
 }
 
@@ -23,12 +48,170 @@ func (c *constructor) Runnable() []runnable {
 	return c.runnable
 }
 
-func (c *constructor) Construct(cfg config.Submitter) (Submitter, error) {
+func (c *constructor) State() map[string]statefulSubmitter {
+	return c.state
+}
+
+// stateful returns the submitter from the previous configuration that has the same identity as the
+// one described by cfg if it is compatible, and otherwise constructs a new one with fn. The identity
+// is the explicit id if one is provided and the path to the submitter in the config otherwise.
+func (c *constructor) stateful(path, id string, cfg config.Submitter, fn func() (Submitter, error)) (Submitter, error) {
+	key := path
+	if id != "" {
+		key = "id:" + id
+	}
+	if _, exists := c.state[key]; exists {
+		return nil, errs.Errorf("duplicate submitter id %q", id)
+	}
+
+	if prev, ok := c.prev[key]; ok && compatibleConfigs(prev.cfg, cfg) {
+		c.state[key] = statefulSubmitter{cfg: cfg, sub: prev.sub}
+		return prev.sub, nil
+	}
+
+	sub, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	c.state[key] = statefulSubmitter{cfg: cfg, sub: sub}
+	return sub, nil
+}
+
+// compatibleConfigs returns true if a submitter constructed with the prev config can be used in
+// place of one constructed with the next config.
+func compatibleConfigs(prev, next config.Submitter) bool {
+	switch next := next.(type) {
+	case config.HydratorSubmitter:
+		prev, ok := prev.(config.HydratorSubmitter)
+		return ok && prev.LiveBufferSize == next.LiveBufferSize
+
+	case config.PrometheusSubmitter:
+		prev, ok := prev.(config.PrometheusSubmitter)
+		if !ok {
+			return false
+		}
+		pb, nb := slices.Sorted(slices.Values(prev.Buckets)), slices.Sorted(slices.Values(next.Buckets))
+		return prev.Namespace == next.Namespace && slices.Equal(pb, nb) &&
+			prev.LiveBufferSize == next.LiveBufferSize
+
+	case config.TraceBufferSubmitter:
+		prev, ok := prev.(config.TraceBufferSubmitter)
+		if !ok {
+			return false
+		}
+		return prev.BufferSize == next.BufferSize && prev.Filter == next.Filter &&
+			prev.LiveBufferSize == next.LiveBufferSize
+
+	default:
+		return false
+	}
+}
+
+// shadowSink returns a sink standing in for cfg if it is an output.
+func (c *constructor) shadowSink(cfg config.Submitter) (Submitter, bool) {
+	switch cfg := cfg.(type) {
+	case config.HTTPSubmitter:
+		return NewShadowSinkSubmitter("HTTPSubmitter", map[string]string{"endpoint": config.Redact(cfg.Endpoint)}), true
+	case config.OTelSubmitter:
+		return NewShadowSinkSubmitter("OTelSubmitter", map[string]string{"endpoint": config.Redact(cfg.Endpoint)}), true
+	case config.PrometheusSubmitter:
+		return NewShadowSinkSubmitter("PrometheusSubmitter", nil), true
+	case config.HydratorSubmitter:
+		return NewShadowSinkSubmitter("HydratorSubmitter", nil), true
+	case config.TraceBufferSubmitter:
+		return NewShadowSinkSubmitter("TraceBufferSubmitter", nil), true
+	case config.SyslogSubmitter:
+		return NewShadowSinkSubmitter("SyslogSubmitter", map[string]string{"address": cfg.Address}), true
+	case config.StatsDSubmitter:
+		return NewShadowSinkSubmitter("StatsDSubmitter", map[string]string{"address": cfg.Address}), true
+	case config.ConsoleSubmitter:
+		return NewShadowSinkSubmitter("ConsoleSubmitter", map[string]string{"output": cfg.Output}), true
+	case config.FileSubmitter:
+		return NewShadowSinkSubmitter("FileSubmitter", map[string]string{"dir": cfg.Dir}), true
+	default:
+		return nil, false
+	}
+}
+
+// liveSize returns the live buffer size configured for cfg, or zero for the default.
+func liveSize(cfg config.Submitter) int {
+	switch cfg := cfg.(type) {
+	case config.FilterSubmitter:
+		return cfg.LiveBufferSize
+	case config.GrouperSubmitter:
+		return cfg.LiveBufferSize
+	case config.HTTPSubmitter:
+		return cfg.LiveBufferSize
+	case config.OTelSubmitter:
+		return cfg.LiveBufferSize
+	case config.PrometheusSubmitter:
+		return cfg.LiveBufferSize
+	case config.HydratorSubmitter:
+		return cfg.LiveBufferSize
+	case config.TraceBufferSubmitter:
+		return cfg.LiveBufferSize
+	case config.FileSubmitter:
+		return cfg.LiveBufferSize
+	case config.ConsoleSubmitter:
+		return cfg.LiveBufferSize
+	case config.SyslogSubmitter:
+		return cfg.LiveBufferSize
+	case config.StatsDSubmitter:
+		return cfg.LiveBufferSize
+	case config.SamplerSubmitter:
+		return cfg.LiveBufferSize
+	case config.TailSamplerSubmitter:
+		return cfg.LiveBufferSize
+	case config.DedupSubmitter:
+		return cfg.LiveBufferSize
+	case config.TransformSubmitter:
+		return cfg.LiveBufferSize
+	case config.RedactSubmitter:
+		return cfg.LiveBufferSize
+	case config.ExtractSubmitter:
+		return cfg.LiveBufferSize
+	case config.NullSubmitter:
+		return cfg.LiveBufferSize
+	default:
+		return 0
+	}
+}
+
+// Construct constructs the submitter described by cfg, wrapping it to measure its submit time if
+// profiling is enabled.
+func (c *constructor) Construct(path string, cfg config.Submitter) (Submitter, error) {
+	sub, err := c.construct(path, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cfg.(config.NamedSubmitter); ok {
+		return sub, nil
+	}
+
+	if live := liveOf(sub); live != nil {
+		live.configure(liveSize(cfg), c.env.Filter)
+	}
+	if lt, ok := sub.(interface{ lockWait() *lockTimer }); ok {
+		lt.lockWait().enable(c.profile)
+	}
+	if c.profile {
+		sub = newProfiledSubmitter(sub)
+	}
+	return sub, nil
+}
+
+func (c *constructor) construct(path string, cfg config.Submitter) (Submitter, error) {
+	if c.shadow {
+		if sink, ok := c.shadowSink(cfg); ok {
+			return sink, nil
+		}
+	}
+
 	switch cfg := cfg.(type) {
 	case config.MultiSubmitter:
 		subs := make([]Submitter, 0, len(cfg))
-		for _, scfg := range cfg {
-			sub, err := c.Construct(scfg)
+		for i, scfg := range cfg {
+			sub, err := c.Construct(path+"/"+strconv.Itoa(i), scfg)
 			if err != nil {
 				return nil, err
 			}
@@ -50,7 +233,7 @@ func (c *constructor) Construct(cfg config.Submitter) (Submitter, error) {
 		if err != nil {
 			return nil, err
 		}
-		sub, err := c.Construct(cfg.Submitter)
+		sub, err := c.Construct(path+"/submitter", cfg.Submitter)
 		if err != nil {
 			return nil, err
 		}
@@ -60,7 +243,7 @@ func (c *constructor) Construct(cfg config.Submitter) (Submitter, error) {
 		), nil
 
 	case config.GrouperSubmitter:
-		sub, err := c.Construct(cfg.Submitter)
+		sub, err := c.Construct(path+"/submitter", cfg.Submitter)
 		if err != nil {
 			return nil, err
 		}
@@ -97,20 +280,237 @@ func (c *constructor) Construct(cfg config.Submitter) (Submitter, error) {
 		return os, nil
 
 	case config.PrometheusSubmitter:
-		return NewPrometheusSubmitter(
-			cfg.Namespace,
-			cfg.Buckets,
-		), nil
+		return c.stateful(path, cfg.ID, cfg, func() (Submitter, error) {
+			return NewPrometheusSubmitter(
+				cfg.Namespace,
+				cfg.Buckets,
+			), nil
+		})
 
 	case config.HydratorSubmitter:
-		return NewHydratorSubmitter(), nil
+		return c.stateful(path, cfg.ID, cfg, func() (Submitter, error) {
+			return NewHydratorSubmitter(), nil
+		})
 
 	case config.TraceBufferSubmitter:
-		fil, err := c.env.Filter.Parse(cfg.Filter)
+		return c.stateful(path, cfg.ID, cfg, func() (Submitter, error) {
+			fil, err := c.env.Filter.Parse(cfg.Filter)
+			if err != nil {
+				return nil, err
+			}
+			return NewTraceBufferSubmitter(cfg.BufferSize, fil), nil
+		})
+
+	case config.FileSubmitter:
+		fs, err := NewFileSubmitter(cfg.Dir, FileOptions{
+			Format:         cfg.Format,
+			RotateSize:     cfg.RotateSize,
+			RotateInterval: cfg.RotateInterval,
+			Compress:       cfg.Compress,
+			MaxSegments:    cfg.MaxSegments,
+			MaxAge:         cfg.MaxAge,
+			Sync:           cfg.Sync,
+			FlushInterval:  cfg.FlushInterval,
+			MaxBatchSize:   cfg.MaxBatchSize,
+		})
 		if err != nil {
 			return nil, err
 		}
-		return NewTraceBufferSubmitter(cfg.BufferSize, fil), nil
+		c.runnable = append(c.runnable, fs)
+
+		return fs, nil
+
+	case config.ConsoleSubmitter:
+		var w *os.File
+		switch cfg.Output {
+		case "", "stderr":
+			w = os.Stderr
+		case "stdout":
+			w = os.Stdout
+		default:
+			return nil, errs.Errorf("unknown console output %q", cfg.Output)
+		}
+		var color bool
+		switch cfg.Color {
+		case "", "auto":
+			color = isTerminal(w)
+		case "always":
+			color = true
+		case "never":
+		default:
+			return nil, errs.Errorf("unknown console color %q", cfg.Color)
+		}
+		cs, err := NewConsoleSubmitter(w, cfg.Format, color, cfg.Verbose)
+		if err != nil {
+			return nil, err
+		}
+		if cs.format == ConsoleTree {
+			c.runnable = append(c.runnable, cs)
+		}
+
+		return cs, nil
+
+	case config.SyslogSubmitter:
+		ss, err := NewSyslogSubmitter(SyslogOptions{
+			Network:       cfg.Network,
+			Address:       cfg.Address,
+			Facility:      cfg.Facility,
+			Hostname:      cfg.Hostname,
+			AppName:       cfg.AppName,
+			SDID:          cfg.SDID,
+			FlushInterval: cfg.FlushInterval,
+			MaxBatchSize:  cfg.MaxBatchSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.runnable = append(c.runnable, ss)
+
+		return ss, nil
+
+	case config.StatsDSubmitter:
+		ss, err := NewStatsDSubmitter(StatsDOptions{
+			Address:       cfg.Address,
+			Prefix:        cfg.Prefix,
+			Tags:          cfg.Tags,
+			TagKeys:       cfg.TagKeys,
+			PacketSize:    cfg.PacketSize,
+			FlushInterval: cfg.FlushInterval,
+			MaxBatchSize:  cfg.MaxBatchSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.runnable = append(c.runnable, ss)
+
+		return ss, nil
+
+	case config.SamplerSubmitter:
+		sub, err := c.Construct(path+"/submitter", cfg.Submitter)
+		if err != nil {
+			return nil, err
+		}
+
+		switch cfg.Mode {
+		case SampleProbability:
+			if cfg.Probability <= 0 || cfg.Probability > 1 {
+				return nil, errs.Errorf("sampler probability must be in (0, 1]: %v", cfg.Probability)
+			}
+			return NewProbabilitySampler(cfg.Probability, sub), nil
+
+		case SampleRate, SampleKeyRate:
+			if cfg.Rate <= 0 {
+				return nil, errs.Errorf("sampler rate must be positive: %v", cfg.Rate)
+			}
+			if cfg.Mode == SampleKeyRate && cfg.Key == "" {
+				return nil, errs.Errorf("key_rate sampler requires a key")
+			}
+			return NewKeyRateSampler(cfg.Key, cfg.Rate, cfg.Burst, sub), nil
+
+		default:
+			return nil, errs.Errorf("unknown sampler mode %q", cfg.Mode)
+		}
+
+	case config.TailSamplerSubmitter:
+		sub, err := c.Construct(path+"/submitter", cfg.Submitter)
+		if err != nil {
+			return nil, err
+		}
+
+		policy := TailPolicy{
+			Errors:      cfg.Errors,
+			MinDuration: cfg.MinDuration,
+			Probability: cfg.Probability,
+		}
+		if cfg.Filter != "" {
+			policy.Filter, err = c.env.Filter.Parse(cfg.Filter)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		ts := NewTailSamplerSubmitter(
+			policy,
+			cfg.Timeout,
+			cfg.MaxTraces,
+			cfg.MaxSpans,
+			sub,
+		)
+		c.runnable = append(c.runnable, ts)
+
+		return ts, nil
+
+	case config.DedupSubmitter:
+		sub, err := c.Construct(path+"/submitter", cfg.Submitter)
+		if err != nil {
+			return nil, err
+		}
+
+		ds := NewDedupSubmitter(
+			cfg.Fields,
+			cfg.Window,
+			sub,
+		)
+		c.runnable = append(c.runnable, ds)
+
+		return ds, nil
+
+	case config.TransformSubmitter:
+		transforms, err := configTransforms(cfg.Operations)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := c.Construct(path+"/submitter", cfg.Submitter)
+		if err != nil {
+			return nil, err
+		}
+		return NewTransformSubmitter(
+			transforms,
+			sub,
+		), nil
+
+	case config.RedactSubmitter:
+		rules, err := configRedactRules(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateGlobs(cfg.DenyKeys); err != nil {
+			return nil, err
+		}
+		var salt string
+		switch cfg.Mode {
+		case "", "mask":
+		case "hash":
+			if cfg.Salt == "" {
+				return nil, errs.Errorf("redact mode hash requires a salt")
+			}
+			salt = cfg.Salt
+		default:
+			return nil, errs.Errorf("unknown redact mode %q", cfg.Mode)
+		}
+		sub, err := c.Construct(path+"/submitter", cfg.Submitter)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedactSubmitter(
+			rules,
+			cfg.DenyKeys,
+			salt,
+			sub,
+		), nil
+
+	case config.ExtractSubmitter:
+		sub, err := c.Construct(path+"/submitter", cfg.Submitter)
+		if err != nil {
+			return nil, err
+		}
+		return NewExtractSubmitter(
+			cfg.Key,
+			cfg.Format,
+			cfg.Pattern,
+			cfg.Prefix,
+			sub,
+		)
 
 	case config.NullSubmitter:
 		return NewNullSubmitter(), nil
@@ -119,3 +519,93 @@ func (c *constructor) Construct(cfg config.Submitter) (Submitter, error) {
 		return nil, errs.Errorf("unknown submitter type %T", cfg)
 	}
 }
+
+// configRedactRules returns the built-in detectors named in the config, or all of them if none
+// are named, followed by the custom rules.
+func configRedactRules(cfg config.RedactSubmitter) ([]RedactRule, error) {
+	rules := RedactBuiltins()
+	if len(cfg.Detectors) > 0 {
+		rules = rules[:0]
+		for _, name := range cfg.Detectors {
+			rule, ok := RedactBuiltin(name)
+			if !ok {
+				return nil, errs.Errorf("unknown redact detector %q", name)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	for i, rc := range cfg.Rules {
+		if rc.Name == "" || rc.Pattern == "" {
+			return nil, errs.Errorf("redact rule %d requires a name and pattern", i)
+		}
+		rule, err := RedactPattern(rc.Name, rc.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// configTransforms returns the transforms described by the operations.
+func configTransforms(ops []config.TransformOperation) ([]Transform, error) {
+	transforms := make([]Transform, 0, len(ops))
+	for i, op := range ops {
+		need := func(ok bool, what string) error {
+			if ok {
+				return nil
+			}
+			return errs.Errorf("transform operation %d (%s) requires %s", i, op.Op, what)
+		}
+
+		var err error
+		switch op.Op {
+		case "drop", "keep", "hash", "truncate":
+			err = need(len(op.Keys) > 0, "keys")
+			if err == nil {
+				err = validateGlobs(op.Keys)
+			}
+		case "rename", "copy", "lookup":
+			err = need(op.Key != "" && op.To != "", "key and to")
+		case "set":
+			err = need(op.Key != "" && op.Value != nil, "key and value")
+		case "convert":
+			_, ok := transformKinds[op.As]
+			err = need(op.Key != "" && ok, "key and as of string, int, uint, float, bool or duration")
+		default:
+			err = errs.Errorf("unknown transform operation %q", op.Op)
+		}
+		if err == nil && op.Op == "truncate" {
+			err = need(op.Length > 0, "a positive length")
+		}
+		// an unkeyed hash of values like user ids is easily reversed by hashing guesses.
+		if err == nil && op.Op == "hash" {
+			err = need(op.Salt != "", "a salt")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case "drop":
+			transforms = append(transforms, TransformDrop(op.Keys...))
+		case "keep":
+			transforms = append(transforms, TransformKeep(op.Keys...))
+		case "rename":
+			transforms = append(transforms, TransformRename(op.Key, op.To))
+		case "set":
+			transforms = append(transforms, TransformSet(decodeAnnotation(op.Key, op.Value)))
+		case "copy":
+			transforms = append(transforms, TransformCopy(op.Key, op.To))
+		case "hash":
+			transforms = append(transforms, TransformHash(op.Salt, op.Keys...))
+		case "truncate":
+			transforms = append(transforms, TransformTruncate(op.Length, op.Keys...))
+		case "convert":
+			transforms = append(transforms, TransformConvert(op.Key, op.As))
+		case "lookup":
+			transforms = append(transforms, TransformLookup(op.Key, op.To, op.Table, op.Default))
+		}
+	}
+	return transforms, nil
+}
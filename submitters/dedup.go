@@ -0,0 +1,193 @@
+package submitters
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unique"
+
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/internal/group"
+)
+
+const (
+	defaultDedupWindow = time.Minute
+	maxDedupKeys       = 10000
+)
+
+var defaultDedupFields = []string{"file", "line", "message"}
+
+type dedupEntry struct {
+	group      []hydrant.Annotation
+	first      time.Time
+	last       time.Time
+	suppressed uint64
+}
+
+// DedupSubmitter forwards the first event with each combination of values of its fields and
+// suppresses the repeats for a window. When the window closes, it forwards a summary event with
+// the fields, the suppressed_count, and the first_timestamp and last_timestamp of the events.
+// Events without all of the fields are forwarded unchanged.
+type DedupSubmitter struct {
+	grouper *group.Grouper
+	fields  []string
+	window  time.Duration
+	sub     Submitter
+	live    liveBuffer
+
+	stats struct {
+		received    atomic.Uint64
+		forwarded   atomic.Uint64
+		suppressed  atomic.Uint64
+		summaries   atomic.Uint64
+		passthrough atomic.Uint64
+	}
+
+	wait    lockTimer
+	mu      sync.Mutex
+	entries map[unique.Handle[string]]*dedupEntry
+}
+
+// NewDedupSubmitter returns a DedupSubmitter keyed by fields, defaulting to file, line and
+// message, that suppresses repeats for window, defaulting to a minute. At most 10000 keys are
+// tracked at once, and the events for any more are forwarded unchanged.
+func NewDedupSubmitter(fields []string, window time.Duration, sub Submitter) *DedupSubmitter {
+	if len(fields) == 0 {
+		fields = defaultDedupFields
+	}
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	return &DedupSubmitter{
+		grouper: group.NewGrouper(fields),
+		fields:  fields,
+		window:  window,
+		sub:     sub,
+		live:    newLiveBuffer(),
+		entries: make(map[unique.Handle[string]]*dedupEntry),
+	}
+}
+
+func (d *DedupSubmitter) Children() []Submitter {
+	return []Submitter{d.sub}
+}
+
+func (d *DedupSubmitter) ExtraData() any {
+	return map[string]string{
+		"fields": strings.Join(d.fields, ","),
+		"window": d.window.String(),
+	}
+}
+
+func (d *DedupSubmitter) lockWait() *lockTimer { return &d.wait }
+
+func (d *DedupSubmitter) Run(ctx context.Context) {
+	ticker := time.NewTicker(max(d.window/4, 100*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := drainContext(ctx)
+			defer cancel()
+			d.close(ctx, time.Time{})
+			return
+
+		case now := <-ticker.C:
+			d.close(ctx, now)
+		}
+	}
+}
+
+// Flush closes every window, forwarding their summaries, and then flushes the child submitter.
+func (d *DedupSubmitter) Flush(ctx context.Context) error {
+	d.close(ctx, time.Time{})
+	return flushChildren(ctx, d)
+}
+
+func (d *DedupSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	d.live.Record(ev)
+	d.stats.received.Add(1)
+
+	key, ok := d.grouper.Group(ev)
+	if !ok {
+		d.stats.passthrough.Add(1)
+		d.sub.Submit(ctx, ev)
+		return
+	}
+
+	now := time.Now()
+
+	d.wait.lock(&d.mu)
+	if e := d.entries[key]; e != nil {
+		e.suppressed++
+		e.last = now
+		d.mu.Unlock()
+		d.stats.suppressed.Add(1)
+		return
+	}
+	if len(d.entries) < maxDedupKeys {
+		d.entries[key] = &dedupEntry{group: d.grouper.Annotations(ev), first: now, last: now}
+	}
+	d.mu.Unlock()
+
+	d.stats.forwarded.Add(1)
+	d.sub.Submit(ctx, ev)
+}
+
+// close ends the windows that started at least a window before now, or all of them if now is
+// zero, and forwards summaries for the ones that suppressed events.
+func (d *DedupSubmitter) close(ctx context.Context, now time.Time) {
+	var summaries []hydrant.Event
+
+	d.mu.Lock()
+	for key, e := range d.entries {
+		if !now.IsZero() && now.Sub(e.first) < d.window {
+			continue
+		}
+		delete(d.entries, key)
+		if e.suppressed == 0 {
+			continue
+		}
+		summaries = append(summaries, append(e.group,
+			hydrant.Timestamp("timestamp", time.Now()),
+			hydrant.Uint("suppressed_count", e.suppressed),
+			hydrant.Timestamp("first_timestamp", e.first),
+			hydrant.Timestamp("last_timestamp", e.last),
+		))
+	}
+	d.mu.Unlock()
+
+	for _, ev := range summaries {
+		d.stats.summaries.Add(1)
+		d.sub.Submit(ctx, ev)
+	}
+}
+
+func (d *DedupSubmitter) Stats() []Stat {
+	d.mu.Lock()
+	active := uint64(len(d.entries))
+	d.mu.Unlock()
+	return []Stat{
+		{"received", d.stats.received.Load()},
+		{"forwarded", d.stats.forwarded.Load()},
+		{"suppressed", d.stats.suppressed.Load()},
+		{"summaries", d.stats.summaries.Load()},
+		{"passthrough", d.stats.passthrough.Load()},
+		{"keys_active", active},
+	}
+}
+
+func (d *DedupSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(d)),
+		"/live":  d.live.Handler(),
+		"/sub":   d.sub.Handler(),
+		"/stats": statsHandler(d.Stats),
+	}
+}
@@ -0,0 +1,42 @@
+package submitters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+)
+
+func TestDedup(t *testing.T) {
+	null := NewNullSubmitter()
+	dd := NewDedupSubmitter(nil, time.Minute, null)
+
+	log := func(message string) hydrant.Event {
+		return hydrant.Event{
+			hydrant.String("file", "main.go"),
+			hydrant.Int("line", 10),
+			hydrant.String("message", message),
+		}
+	}
+	for range 5 {
+		dd.Submit(t.Context(), log("connection refused"))
+	}
+	dd.Submit(t.Context(), log("other"))
+	dd.Submit(t.Context(), hydrant.Event{hydrant.String("name", "span")})
+	assert.Equal(t, null.stats.received.Load(), uint64(3))
+
+	// closing the window sends a summary of the suppressed events.
+	assert.NoError(t, dd.Flush(t.Context()))
+	assert.Equal(t, null.stats.received.Load(), uint64(4))
+
+	summary := null.live.buf.Get()[3]
+	var count uint64
+	for _, a := range summary {
+		if a.Key == "suppressed_count" {
+			count, _ = a.Value.Uint()
+		}
+	}
+	assert.Equal(t, count, uint64(4))
+}
@@ -0,0 +1,439 @@
+package submitters
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"iter"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/histdb/histdb/flathist"
+	"github.com/klauspost/compress/zstd"
+	"github.com/zeebo/errs/v2"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/internal/rw"
+	"storj.io/hydrant/value"
+)
+
+// Event file formats.
+const (
+	// FileNative stores every event as a varint length followed by its Event.AppendTo encoding.
+	FileNative = "native"
+
+	// FileJSONL stores every event as a line holding a JSON array of annotations with their key,
+	// kind and value, which preserves the kinds of the values.
+	FileJSONL = "jsonl"
+)
+
+// maxFileEventSize bounds the size of an event read from a native segment so that a corrupt
+// length can not exhaust memory.
+const maxFileEventSize = 64 << 20
+
+// fileExtensions are the extensions of the segments in each format. Compressed segments have
+// .zst appended.
+var fileExtensions = map[string]string{
+	FileNative: ".bin",
+	FileJSONL:  ".jsonl",
+}
+
+// fileKinds are the names of the value kinds in the JSONL format.
+var fileKinds = map[value.Kind]string{
+	value.KindEmpty:     "empty",
+	value.KindString:    "string",
+	value.KindBytes:     "bytes",
+	value.KindHistogram: "histogram",
+	value.KindTraceId:   "trace_id",
+	value.KindSpanId:    "span_id",
+	value.KindInt:       "int",
+	value.KindUint:      "uint",
+	value.KindDuration:  "duration",
+	value.KindFloat:     "float",
+	value.KindBool:      "bool",
+	value.KindTimestamp: "timestamp",
+}
+
+type fileAnnotation struct {
+	Key   string `json:"key"`
+	Kind  string `json:"kind"`
+	Value any    `json:"value"`
+}
+
+// appendNativeEvent appends the event to buf in the native format.
+func appendNativeEvent(buf []byte, ev hydrant.Event) []byte {
+	enc := ev.AppendTo(nil)
+	buf = rw.AppendVarint(buf, uint64(len(enc)))
+	return append(buf, enc...)
+}
+
+// appendJSONEvent appends the event to buf as a line in the JSONL format. Bytes and histograms
+// are base64 encoded, ids are hex, durations and timestamps are strings, and floats that are not
+// finite are strings.
+func appendJSONEvent(buf []byte, ev hydrant.Event) ([]byte, error) {
+	out := make([]fileAnnotation, len(ev))
+	for i, a := range ev {
+		fa := fileAnnotation{Key: a.Key, Kind: fileKinds[a.Value.Kind()]}
+		switch a.Value.Kind() {
+		case value.KindHistogram:
+			h, _ := a.Value.Histogram()
+			fa.Value = h.AppendTo(nil)
+		case value.KindTraceId:
+			x, _ := a.Value.TraceId()
+			fa.Value = hex.EncodeToString(x[:])
+		case value.KindSpanId:
+			x, _ := a.Value.SpanId()
+			fa.Value = hex.EncodeToString(x[:])
+		case value.KindDuration:
+			x, _ := a.Value.Duration()
+			fa.Value = x.String()
+		case value.KindTimestamp:
+			x, _ := a.Value.Timestamp()
+			fa.Value = x.Format(time.RFC3339Nano)
+		case value.KindFloat:
+			x, _ := a.Value.Float()
+			fa.Value = x
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				fa.Value = strconv.FormatFloat(x, 'g', -1, 64)
+			}
+		default:
+			fa.Value = a.Value.AsAny()
+		}
+		out[i] = fa
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return buf, err
+	}
+	buf = append(buf, data...)
+	return append(buf, '\n'), nil
+}
+
+// EventReader reads the events in a file written by a FileSubmitter.
+type EventReader struct {
+	format string
+	br     *bufio.Reader
+	close  func() error
+	buf    []byte
+}
+
+// NewEventReader returns an EventReader for the events in r in the format, FileNative or
+// FileJSONL.
+func NewEventReader(r io.Reader, format string) (*EventReader, error) {
+	if _, ok := fileExtensions[format]; !ok {
+		return nil, errs.Errorf("unknown file format %q", format)
+	}
+	return &EventReader{
+		format: format,
+		br:     bufio.NewReaderSize(r, 64<<10),
+		close:  func() error { return nil },
+	}, nil
+}
+
+// OpenEventFile returns an EventReader for the segment at path. The format is detected from the
+// extension, and segments ending in .zst are decompressed.
+func OpenEventFile(path string) (*EventReader, error) {
+	name := filepath.Base(path)
+	compressed := strings.HasSuffix(name, ".zst")
+	name = strings.TrimSuffix(name, ".zst")
+
+	format := ""
+	for f, ext := range fileExtensions {
+		if strings.HasSuffix(name, ext) {
+			format = f
+		}
+	}
+	if format == "" {
+		return nil, errs.Errorf("unknown event file extension: %q", path)
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = fh
+	closeFn := fh.Close
+	if compressed {
+		dec, err := zstd.NewReader(fh)
+		if err != nil {
+			_ = fh.Close()
+			return nil, err
+		}
+		r = dec
+		closeFn = func() error { dec.Close(); return fh.Close() }
+	}
+
+	er, err := NewEventReader(r, format)
+	if err != nil {
+		_ = closeFn()
+		return nil, err
+	}
+	er.close = closeFn
+	return er, nil
+}
+
+// Next returns the next event, or io.EOF if there are no more. A segment that ends partway
+// through an event, as when the process writing it crashed, returns io.ErrUnexpectedEOF.
+func (r *EventReader) Next() (hydrant.Event, error) {
+	if r.format == FileJSONL {
+		return r.nextJSON()
+	}
+	return r.nextNative()
+}
+
+func (r *EventReader) nextNative() (hydrant.Event, error) {
+	head, err := r.br.Peek(9)
+	if len(head) == 0 {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	rd := rw.NewReader(head)
+	size := rd.ReadVarint()
+	rem, err := rd.Done()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if _, err := r.br.Discard(len(head) - len(rem)); err != nil {
+		return nil, err
+	}
+
+	if size > maxFileEventSize {
+		return nil, errs.Errorf("event of %d bytes is too large", size)
+	}
+	if uint64(cap(r.buf)) < size {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	if _, err := io.ReadFull(r.br, r.buf); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+
+	var ev hydrant.Event
+	if _, err := ev.ReadFrom(r.buf); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+func (r *EventReader) nextJSON() (hydrant.Event, error) {
+	for {
+		line, err := r.br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			r.buf = append(r.buf[:0], line...)
+			for err == bufio.ErrBufferFull {
+				line, err = r.br.ReadSlice('\n')
+				r.buf = append(r.buf, line...)
+			}
+			line = r.buf
+		}
+		if err == io.EOF && len(bytes.TrimSpace(line)) > 0 {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		return parseJSONEvent(line)
+	}
+}
+
+// Close closes the underlying file if the reader was opened with OpenEventFile.
+func (r *EventReader) Close() error { return r.close() }
+
+// All returns the remaining events. It stops after the first error, which is yielded with a nil
+// event.
+func (r *EventReader) All() iter.Seq2[hydrant.Event, error] {
+	return func(yield func(hydrant.Event, error) bool) {
+		for {
+			ev, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(ev, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ReadEvents returns the events in the files at the paths in order. Directories are replaced by
+// the segments in them, oldest first. It stops after the first error, which is yielded with a nil
+// event.
+func ReadEvents(paths ...string) iter.Seq2[hydrant.Event, error] {
+	return func(yield func(hydrant.Event, error) bool) {
+		for _, path := range paths {
+			files, err := eventFiles(path)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, file := range files {
+				r, err := OpenEventFile(file)
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				for ev, err := range r.All() {
+					if err != nil {
+						err = errs.Errorf("reading %q: %w", file, err)
+					}
+					if !yield(ev, err) || err != nil {
+						_ = r.Close()
+						return
+					}
+				}
+				if err := r.Close(); err != nil {
+					yield(nil, err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// eventFiles returns the path if it is a file, or the segments in it if it is a directory.
+func eventFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".zst")
+		if !e.Type().IsRegular() || !strings.HasPrefix(name, fileSegmentPrefix) {
+			continue
+		}
+		for _, ext := range fileExtensions {
+			if strings.HasSuffix(name, ext) {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// parseJSONEvent parses a line in the JSONL format.
+func parseJSONEvent(line []byte) (hydrant.Event, error) {
+	var anns []struct {
+		Key   string          `json:"key"`
+		Kind  string          `json:"kind"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(line, &anns); err != nil {
+		return nil, err
+	}
+
+	ev := make(hydrant.Event, len(anns))
+	for i, fa := range anns {
+		v, err := parseJSONValue(fa.Kind, fa.Value)
+		if err != nil {
+			return nil, errs.Errorf("annotation %q: %w", fa.Key, err)
+		}
+		ev[i] = hydrant.Annotation{Key: fa.Key, Value: v}
+	}
+	return ev, nil
+}
+
+func parseJSONValue(kind string, raw json.RawMessage) (value.Value, error) {
+	var s string
+	var b []byte
+	var err error
+
+	switch kind {
+	case "empty":
+		return value.Value{}, nil
+	case "string":
+		err = json.Unmarshal(raw, &s)
+		return value.String(s), err
+	case "bytes":
+		err = json.Unmarshal(raw, &b)
+		return value.Bytes(b), err
+	case "histogram":
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return value.Value{}, err
+		}
+		h := flathist.NewHistogram()
+		if _, err := h.ReadFrom(b); err != nil {
+			return value.Value{}, err
+		}
+		return value.Histogram(h), nil
+	case "trace_id":
+		var x [16]byte
+		err = parseJSONHex(raw, x[:])
+		return value.TraceId(x), err
+	case "span_id":
+		var x [8]byte
+		err = parseJSONHex(raw, x[:])
+		return value.SpanId(x), err
+	case "int":
+		var x int64
+		err = json.Unmarshal(raw, &x)
+		return value.Int(x), err
+	case "uint":
+		var x uint64
+		err = json.Unmarshal(raw, &x)
+		return value.Uint(x), err
+	case "duration":
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return value.Value{}, err
+		}
+		x, err := time.ParseDuration(s)
+		return value.Duration(x), err
+	case "float":
+		var x float64
+		if json.Unmarshal(raw, &s) == nil {
+			x, err = strconv.ParseFloat(s, 64)
+		} else {
+			err = json.Unmarshal(raw, &x)
+		}
+		return value.Float(x), err
+	case "bool":
+		var x bool
+		err = json.Unmarshal(raw, &x)
+		return value.Bool(x), err
+	case "timestamp":
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return value.Value{}, err
+		}
+		x, err := time.Parse(time.RFC3339Nano, s)
+		return value.Timestamp(x), err
+	default:
+		return value.Value{}, errs.Errorf("unknown kind %q", kind)
+	}
+}
+
+func parseJSONHex(raw json.RawMessage, dst []byte) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	if hex.DecodedLen(len(s)) != len(dst) {
+		return errs.Errorf("invalid id length: %q", s)
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
@@ -0,0 +1,65 @@
+package submitters
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"github.com/histdb/histdb/flathist"
+	"storj.io/hydrant"
+)
+
+func TestEventReader(t *testing.T) {
+	h := flathist.NewHistogram()
+	h.Observe(1.5)
+	ev := hydrant.Event{
+		hydrant.String("message", "hello\nworld"),
+		hydrant.Bytes("raw", []byte{0, 1, 2}),
+		hydrant.Histogram("latency", h),
+		hydrant.TraceId("trace_id", [16]byte{1, 2, 3}),
+		hydrant.SpanId("span_id", [8]byte{4, 5, 6}),
+		hydrant.Int("int", -1),
+		hydrant.Uint("uint", math.MaxUint64),
+		hydrant.Duration("duration", time.Second+time.Nanosecond),
+		hydrant.Float("nan", math.NaN()),
+		hydrant.Bool("ok", true),
+		hydrant.Timestamp("timestamp", time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)),
+		{Key: "empty"},
+	}
+
+	for _, format := range []string{FileNative, FileJSONL} {
+		dir := t.TempDir()
+		fs, err := NewFileSubmitter(dir, FileOptions{Format: format, RotateSize: 1})
+		assert.NoError(t, err)
+		fs.Submit(t.Context(), ev)
+		fs.Submit(t.Context(), ev)
+		assert.NoError(t, fs.Flush(t.Context()))
+
+		var got []hydrant.Event
+		for ev, err := range ReadEvents(dir) {
+			assert.NoError(t, err)
+			got = append(got, ev)
+		}
+		assert.Equal(t, len(got), 2)
+		for _, g := range got {
+			assert.Equal(t, g.AppendTo(nil), ev.AppendTo(nil))
+		}
+
+		// a segment cut off partway through an event is an unexpected EOF.
+		var buf []byte
+		if format == FileJSONL {
+			buf, err = appendJSONEvent(nil, ev)
+			assert.NoError(t, err)
+		} else {
+			buf = appendNativeEvent(nil, ev)
+		}
+		r, err := NewEventReader(bytes.NewReader(buf[:len(buf)-2]), format)
+		assert.NoError(t, err)
+		_, err = r.Next()
+		assert.Equal(t, err, io.ErrUnexpectedEOF)
+	}
+}
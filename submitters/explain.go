@@ -0,0 +1,325 @@
+package submitters
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/zeebo/errs/v2"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/filter"
+	"storj.io/hydrant/value"
+)
+
+// maxExplainDepth bounds how deep an explanation follows named references, in case they form a
+// cycle.
+const maxExplainDepth = 64
+
+// Explanation describes the path an event takes through a submitter and its children.
+type Explanation struct {
+	Path  string `json:"path"`
+	Kind  string `json:"kind"`
+	Extra any    `json:"extra,omitempty"`
+
+	// Name is set if the submitter was reached through a named reference.
+	Name string `json:"name,omitempty"`
+
+	// Passed and Steps are set for filters.
+	Passed *bool         `json:"passed,omitempty"`
+	Steps  []filter.Step `json:"steps,omitempty"`
+
+	// Group and NewGroup are set for groupers. The children are explained with the aggregated
+	// event the group would flush if the event were its only one.
+	Group    jsonEvent `json:"group,omitempty"`
+	NewGroup bool      `json:"new_group,omitempty"`
+
+	// Event is set for transforms, redactors and extractors to the event passed on to the children.
+	Event jsonEvent `json:"event,omitempty"`
+
+	// Reached is set for submitters that store or export the event.
+	Reached bool `json:"reached,omitempty"`
+
+	// Note describes anything else that happens to the event.
+	Note string `json:"note,omitempty"`
+
+	Sub []*Explanation `json:"sub,omitempty"`
+}
+
+// Explain returns the path the event would take through the pipeline without submitting it.
+func (s *ConfiguredSubmitter) Explain(ev hydrant.Event) *Explanation {
+	return explain("/sub", "", s.root, ev, 0)
+}
+
+func explain(path, name string, sub Submitter, ev hydrant.Event, depth int) *Explanation {
+	if late, ok := sub.(*lateSubmitter); ok {
+		return explain(path, late.name, late.sub, ev, depth)
+	}
+	sub = unwrap(sub)
+
+	ex := &Explanation{
+		Path:  path,
+		Kind:  reflect.TypeOf(sub).Elem().Name(),
+		Extra: sub.ExtraData(),
+		Name:  name,
+	}
+	if depth >= maxExplainDepth {
+		ex.Note = "too deep"
+		return ex
+	}
+
+	children := func(ev hydrant.Event) {
+		for i, child := range sub.Children() {
+			ex.Sub = append(ex.Sub, explain(childPath(path, sub, i), "", child, ev, depth+1))
+		}
+	}
+
+	switch sub := sub.(type) {
+	case *FilterSubmitter:
+		passed, steps := sub.fil.Explain(ev)
+		ex.Passed, ex.Steps = &passed, steps
+		if passed {
+			children(ev)
+		}
+
+	case *GrouperSubmitter:
+		key, ok := sub.grouper.Group(ev)
+		if !ok {
+			ex.Note = "ungroupable: missing group by fields"
+			break
+		}
+		sub.mu.Lock()
+		_, exists := sub.groups[key]
+		start := sub.start
+		sub.mu.Unlock()
+
+		ge := newGroupedEvents(sub.grouper.Annotations(ev))
+		ex.Group, ex.NewGroup = serializeEvent(ge.event), !exists
+		ge.observe(ev)
+		children(ge.aggregate(start, time.Now()))
+
+	case *MultiSubmitter:
+		children(ev)
+
+	case *PrometheusSubmitter:
+		ex.Reached = true
+		if !hasHistogram(ev, "duration") {
+			ex.Reached, ex.Note = false, "skipped: no duration histogram"
+		}
+
+	case *SamplerSubmitter:
+		ex.Note = "sampled: continues only if kept"
+		children(ev)
+
+	case *TailSamplerSubmitter:
+		ex.Note = "buffered: continues only if its trace is kept"
+		children(ev)
+
+	case *DedupSubmitter:
+		if _, ok := sub.grouper.Group(ev); ok {
+			ex.Note = "deduplicated: continues only if it is the first in its window"
+		}
+		children(ev)
+
+	case *TransformSubmitter:
+		ev = sub.apply(ev)
+		ex.Event = serializeEvent(ev)
+		children(ev)
+
+	case *RedactSubmitter:
+		ev, _ = sub.redact(ev, false)
+		ex.Event = serializeEvent(ev)
+		children(ev)
+
+	case *ExtractSubmitter:
+		ev, _ = sub.extract(ev)
+		ex.Event = serializeEvent(ev)
+		children(ev)
+
+	case *SyslogSubmitter:
+		ex.Reached = slices.ContainsFunc(ev, func(a hydrant.Annotation) bool { return a.Key == "message" })
+		if !ex.Reached {
+			ex.Note = "skipped: no message"
+		}
+
+	case *StatsDSubmitter:
+		ex.Reached = len(sub.appendEvent(nil, ev)) > 0
+		if !ex.Reached {
+			ex.Note = "skipped: not a grouped event or span"
+		}
+
+	case *NullSubmitter:
+		ex.Note = "discarded"
+
+	default:
+		ex.Reached = true
+		children(ev)
+	}
+
+	return ex
+}
+
+// hasHistogram returns true if the event has a histogram for key.
+func hasHistogram(ev hydrant.Event, key string) bool {
+	for _, ann := range ev {
+		if ann.Key == key && ann.Value.Kind() == value.KindHistogram {
+			return true
+		}
+	}
+	return false
+}
+
+// explainHandler explains an event through the pipeline. A POST explains the event in the body.
+// A GET explains an event from the live buffer of the submitter at the path query parameter, the
+// most recent one unless an index is given.
+func (s *ConfiguredSubmitter) explainHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hydrant.Event
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			ev, err = decodeEvent(r)
+		case http.MethodGet:
+			ev, err = s.liveEvent(r.URL.Query().Get("path"), r.URL.Query().Get("index"))
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		enc.Encode(struct {
+			Event   jsonEvent    `json:"event"`
+			Explain *Explanation `json:"explain"`
+		}{serializeEvent(ev), s.Explain(ev)})
+	})
+}
+
+// liveEvent returns an event from the live buffer of the submitter at path.
+func (s *ConfiguredSubmitter) liveEvent(path, index string) (hydrant.Event, error) {
+	live := liveOf(s.lookup(path))
+	if live == nil {
+		return nil, errs.Errorf("no submitter with a live buffer at %q", path)
+	}
+
+	events := live.buf.Get()
+	i := len(events) - 1
+	if index != "" {
+		var err error
+		if i, err = strconv.Atoi(index); err != nil {
+			return nil, err
+		}
+	}
+	if i < 0 || i >= len(events) {
+		return nil, errs.Errorf("no event at index %d of %d", i, len(events))
+	}
+	return events[i], nil
+}
+
+// liveOf returns the live buffer of the submitter.
+func liveOf(sub Submitter) *liveBuffer {
+	switch sub := unwrap(sub).(type) {
+	case *FilterSubmitter:
+		return &sub.live
+	case *GrouperSubmitter:
+		return &sub.live
+	case *MultiSubmitter:
+		return &sub.live
+	case *HTTPSubmitter:
+		return &sub.live
+	case *OTelSubmitter:
+		return &sub.live
+	case *PrometheusSubmitter:
+		return &sub.live
+	case *HydratorSubmitter:
+		return &sub.live
+	case *TraceBufferSubmitter:
+		return &sub.live
+	case *FileSubmitter:
+		return &sub.live
+	case *ConsoleSubmitter:
+		return &sub.live
+	case *SyslogSubmitter:
+		return &sub.live
+	case *StatsDSubmitter:
+		return &sub.live
+	case *SamplerSubmitter:
+		return &sub.live
+	case *TailSamplerSubmitter:
+		return &sub.live
+	case *DedupSubmitter:
+		return &sub.live
+	case *TransformSubmitter:
+		return &sub.live
+	case *RedactSubmitter:
+		return &sub.live
+	case *ExtractSubmitter:
+		return &sub.live
+	case *NullSubmitter:
+		return &sub.live
+	case *ShadowSinkSubmitter:
+		return &sub.live
+	default:
+		return nil
+	}
+}
+
+// decodeEvent reads an event from the request body. It is either an object of keys to values or
+// a list of {"key", "value"} objects like the live buffer returns. Booleans and numbers keep
+// their types, and strings that parse as durations become durations.
+func decodeEvent(r *http.Request) (hydrant.Event, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var ev hydrant.Event
+	var list []struct {
+		Key   string `json:"key"`
+		Value any    `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &list); err == nil {
+		for _, ann := range list {
+			ev = append(ev, decodeAnnotation(ann.Key, ann.Value))
+		}
+		return ev, nil
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, errs.Errorf("event must be an object or a list of annotations")
+	}
+	for _, key := range slices.Sorted(maps.Keys(obj)) {
+		ev = append(ev, decodeAnnotation(key, obj[key]))
+	}
+	return ev, nil
+}
+
+func decodeAnnotation(key string, v any) hydrant.Annotation {
+	switch v := v.(type) {
+	case bool:
+		return hydrant.Bool(key, v)
+	case float64:
+		if v == float64(int64(v)) {
+			return hydrant.Int(key, int64(v))
+		}
+		return hydrant.Float(key, v)
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return hydrant.Duration(key, d)
+		}
+		return hydrant.String(key, v)
+	default:
+		data, _ := json.Marshal(v)
+		return hydrant.String(key, string(data))
+	}
+}
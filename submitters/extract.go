@@ -0,0 +1,265 @@
+package submitters
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/errs/v2"
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+)
+
+// Extract formats.
+const (
+	ExtractRegex  = "regex"
+	ExtractLogfmt = "logfmt"
+	ExtractJSON   = "json"
+)
+
+// ExtractSubmitter parses the string annotation with its key, message by default, and appends the
+// fields it finds to the event as typed annotations: integers, floats, booleans and durations are
+// detected, and everything else is a string. Fields with the key of an existing annotation are
+// skipped. The submitted events are never modified.
+type ExtractSubmitter struct {
+	key    string
+	format string
+	re     *regexp.Regexp
+	prefix string
+	sub    Submitter
+	live   liveBuffer
+
+	stats struct {
+		received  atomic.Uint64
+		extracted atomic.Uint64
+		unmatched atomic.Uint64
+	}
+}
+
+// NewExtractSubmitter returns an ExtractSubmitter that parses the annotation with the key in the
+// format, one of regex, logfmt or json. The regex format uses the named groups of pattern as the
+// fields and the others ignore it. The extracted keys are prefixed with prefix, and nested JSON
+// objects are flattened with dots.
+func NewExtractSubmitter(key, format, pattern, prefix string, sub Submitter) (*ExtractSubmitter, error) {
+	if key == "" {
+		key = "message"
+	}
+	e := &ExtractSubmitter{
+		key:    key,
+		format: format,
+		prefix: prefix,
+		sub:    sub,
+		live:   newLiveBuffer(),
+	}
+
+	switch format {
+	case ExtractRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errs.Errorf("invalid extract pattern: %w", err)
+		}
+		if !slices.ContainsFunc(re.SubexpNames(), func(name string) bool { return name != "" }) {
+			return nil, errs.Errorf("extract pattern %q has no named groups", pattern)
+		}
+		e.re = re
+	case ExtractLogfmt, ExtractJSON:
+	default:
+		return nil, errs.Errorf("unknown extract format %q", format)
+	}
+
+	return e, nil
+}
+
+func (e *ExtractSubmitter) Children() []Submitter {
+	return []Submitter{e.sub}
+}
+
+func (e *ExtractSubmitter) ExtraData() any {
+	extra := map[string]string{"key": e.key, "format": e.format}
+	if e.re != nil {
+		extra["pattern"] = e.re.String()
+	}
+	if e.prefix != "" {
+		extra["prefix"] = e.prefix
+	}
+	return extra
+}
+
+func (e *ExtractSubmitter) Flush(ctx context.Context) error { return flushChildren(ctx, e) }
+
+func (e *ExtractSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	e.live.Record(ev)
+	e.stats.received.Add(1)
+
+	out, ok := e.extract(ev)
+	if ok {
+		e.stats.extracted.Add(1)
+	} else {
+		e.stats.unmatched.Add(1)
+	}
+	e.sub.Submit(ctx, out)
+}
+
+// extract returns the event with the extracted annotations appended and whether there were any.
+func (e *ExtractSubmitter) extract(ev hydrant.Event) (hydrant.Event, bool) {
+	var msg string
+	var found bool
+	for _, a := range ev {
+		if a.Key == e.key {
+			msg, found = a.Value.String()
+			break
+		}
+	}
+	if !found {
+		return ev, false
+	}
+
+	out := slices.Clip(ev)
+	n := len(out)
+	add := func(key string, ann hydrant.Annotation) {
+		ann.Key = e.prefix + key
+		if !slices.ContainsFunc(out, func(a hydrant.Annotation) bool { return a.Key == ann.Key }) {
+			out = append(out, ann)
+		}
+	}
+
+	switch e.format {
+	case ExtractRegex:
+		m := e.re.FindStringSubmatch(msg)
+		for i, name := range e.re.SubexpNames() {
+			if m != nil && name != "" && m[i] != "" {
+				add(name, typedAnnotation("", m[i]))
+			}
+		}
+
+	case ExtractLogfmt:
+		for key, val := range parseLogfmt(msg) {
+			add(key, typedAnnotation("", val))
+		}
+
+	case ExtractJSON:
+		var obj map[string]any
+		dec := json.NewDecoder(strings.NewReader(msg))
+		dec.UseNumber()
+		if dec.Decode(&obj) == nil {
+			flattenJSON("", obj, add)
+		}
+	}
+
+	return out, len(out) > n
+}
+
+func (e *ExtractSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", e.stats.received.Load()},
+		{"extracted", e.stats.extracted.Load()},
+		{"unmatched", e.stats.unmatched.Load()},
+	}
+}
+
+func (e *ExtractSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(e)),
+		"/live":  e.live.Handler(),
+		"/sub":   e.sub.Handler(),
+		"/stats": statsHandler(e.Stats),
+	}
+}
+
+// typedAnnotation returns an annotation for the string, detecting integers, floats, booleans and
+// durations.
+func typedAnnotation(key, s string) hydrant.Annotation {
+	if x, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return hydrant.Int(key, x)
+	}
+	if strings.ContainsAny(s, "0123456789") {
+		if x, err := strconv.ParseFloat(s, 64); err == nil {
+			return hydrant.Float(key, x)
+		}
+		if x, err := time.ParseDuration(s); err == nil {
+			return hydrant.Duration(key, x)
+		}
+	}
+	switch s {
+	case "true":
+		return hydrant.Bool(key, true)
+	case "false":
+		return hydrant.Bool(key, false)
+	}
+	return hydrant.String(key, s)
+}
+
+// flattenJSON calls add for every value in the object, joining the keys of nested objects with
+// dots. Arrays are added as their JSON encoding.
+func flattenJSON(prefix string, obj map[string]any, add func(string, hydrant.Annotation)) {
+	for _, key := range slices.Sorted(maps.Keys(obj)) {
+		v := obj[key]
+		key = prefix + key
+		switch v := v.(type) {
+		case map[string]any:
+			flattenJSON(key+".", v, add)
+		case string:
+			add(key, typedAnnotation("", v))
+		case json.Number:
+			add(key, typedAnnotation("", v.String()))
+		case bool:
+			add(key, hydrant.Bool("", v))
+		case nil:
+		default:
+			data, _ := json.Marshal(v)
+			add(key, hydrant.String("", string(data)))
+		}
+	}
+}
+
+// parseLogfmt returns the key=value pairs in the string. Values may be double quoted with Go
+// escapes, and keys without a value are "true".
+func parseLogfmt(s string) iter.Seq2[string, string] {
+	return func(yield func(key, val string) bool) {
+		for {
+			s = strings.TrimLeft(s, " \t")
+			if s == "" {
+				return
+			}
+
+			end := strings.IndexAny(s, "= \t")
+			if end < 0 {
+				end = len(s)
+			}
+			key := s[:end]
+			s = s[end:]
+
+			val := "true"
+			if strings.HasPrefix(s, "=") {
+				s = s[1:]
+				if strings.HasPrefix(s, `"`) {
+					quoted, err := strconv.QuotedPrefix(s)
+					if err != nil {
+						return
+					}
+					val, _ = strconv.Unquote(quoted)
+					s = s[len(quoted):]
+				} else {
+					end := strings.IndexAny(s, " \t")
+					if end < 0 {
+						end = len(s)
+					}
+					val, s = s[:end], s[end:]
+				}
+			}
+
+			if key != "" && !yield(key, val) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package submitters
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/filter"
+)
+
+func TestExtract(t *testing.T) {
+	construct := func(cfg string) *ExtractSubmitter {
+		var c config.Config
+		assert.NoError(t, json.Unmarshal([]byte(`{"submitter": `+cfg+`}`), &c))
+		sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(c)
+		assert.NoError(t, err)
+		return sub.root.(*ExtractSubmitter)
+	}
+	extract := func(s *ExtractSubmitter, msg string) map[string]any {
+		ev := hydrant.Event{hydrant.String("message", msg), hydrant.String("level", "info")}
+		orig := slices.Clone(ev)
+		out, _ := s.extract(ev)
+		assert.DeepEqual(t, ev, orig)
+
+		got := make(map[string]any)
+		for _, a := range out {
+			got[a.Key] = a.Value.AsAny()
+		}
+		return got
+	}
+
+	re := construct(`{
+		"kind": "extract",
+		"format": "regex",
+		"pattern": "^(?P<method>[A-Z]+) (?P<path>\\S+) took (?P<took>\\S+)$",
+		"prefix": "req.",
+		"submitter": {"kind": "null"}
+	}`)
+	got := extract(re, "GET /foo took 1.5s")
+	assert.Equal(t, got["req.method"], "GET")
+	assert.Equal(t, got["req.path"], "/foo")
+	assert.Equal(t, got["req.took"], 1500*time.Millisecond)
+	assert.Equal(t, len(extract(re, "nope")), 2)
+
+	lf := construct(`{"kind": "extract", "format": "logfmt", "submitter": {"kind": "null"}}`)
+	got = extract(lf, `count=3 ratio=0.5 ok=true msg="hello world" level=debug verbose`)
+	assert.Equal(t, got["count"], int64(3))
+	assert.Equal(t, got["ratio"], 0.5)
+	assert.Equal(t, got["ok"], true)
+	assert.Equal(t, got["msg"], "hello world")
+	assert.Equal(t, got["level"], "info")
+	assert.Equal(t, got["verbose"], true)
+
+	js := construct(`{"kind": "extract", "format": "json", "submitter": {"kind": "null"}}`)
+	got = extract(js, `{"user": {"id": 7, "name": "bob"}, "tags": ["a", "b"], "gone": null}`)
+	assert.Equal(t, got["user.id"], int64(7))
+	assert.Equal(t, got["user.name"], "bob")
+	assert.Equal(t, got["tags"], `["a","b"]`)
+	assert.Equal(t, len(got), 5)
+
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{"submitter": {
+		"kind": "extract",
+		"format": "regex",
+		"pattern": "(x)",
+		"submitter": {"kind": "null"}
+	}}`), &cfg))
+	_, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,516 @@
+package submitters
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/zeebo/errs/v2"
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+)
+
+// Fsync policies.
+const (
+	SyncNever  = "never"
+	SyncRotate = "rotate"
+	SyncFlush  = "flush"
+	SyncAlways = "always"
+)
+
+const (
+	defaultFileRotateSize     = 64 << 20
+	defaultFileRotateInterval = time.Hour
+	defaultFileFlushInterval  = time.Second
+	defaultFileBatch          = 10000
+
+	// fileSegmentPrefix starts the names of the segments, followed by the time they were opened.
+	fileSegmentPrefix = "events-"
+	fileSegmentTime   = "20060102T150405.000000000Z"
+)
+
+// FileOptions configures a FileSubmitter. The zero value writes native segments of up to 64MiB
+// or an hour of events, keeps all of them, and syncs them when they are closed.
+type FileOptions struct {
+	// Format is FileNative or FileJSONL.
+	Format string
+
+	// RotateSize and RotateInterval close the current segment and start a new one when it holds
+	// that many bytes or has been open that long.
+	RotateSize     int64
+	RotateInterval time.Duration
+
+	// Compress compresses closed segments with zstd.
+	Compress bool
+
+	// MaxSegments and MaxAge remove the oldest closed segments when there are more than that many
+	// or they were last written longer ago than that, if positive.
+	MaxSegments int
+	MaxAge      time.Duration
+
+	// Sync is when the segments are synced to disk: SyncNever, SyncRotate when they are closed,
+	// SyncFlush after every periodic or explicit flush, or SyncAlways after every event.
+	Sync string
+
+	// FlushInterval is how often queued events are written to the segment, defaulting to a
+	// second, and MaxBatchSize is how many events are queued, defaulting to 10000.
+	FlushInterval time.Duration
+	MaxBatchSize  int
+}
+
+// FileSubmitter writes events to segments in a directory, rotating them by size and age.
+// Segments are named by the time they were opened so they sort in the order they were written.
+// Events are queued in memory and written by Run or Flush, so submitting never waits on the disk.
+// Events that arrive when the queue is full are dropped, and events that can not be written are
+// lost, and writing resumes in a new segment.
+type FileSubmitter struct {
+	dir  string
+	opts FileOptions
+	ext  string
+	live liveBuffer
+
+	stats struct {
+		received     atomic.Uint64
+		dropped      atomic.Uint64
+		written      atomic.Uint64
+		bytesWritten atomic.Uint64
+		lost         atomic.Uint64
+		writeErrors  atomic.Uint64
+		rotations    atomic.Uint64
+		compressed   atomic.Uint64
+		removed      atomic.Uint64
+	}
+
+	wait    lockTimer
+	qmu     sync.Mutex
+	queue   []hydrant.Event
+	trigger chan struct{}
+
+	mu     sync.Mutex // serializes writing the segments
+	f      *os.File
+	w      *bufio.Writer
+	path   string
+	size   int64
+	opened time.Time
+	buf    []byte
+	closed []string // closed segments waiting to be compressed
+
+	maint sync.Mutex // serializes compression and retention
+}
+
+// NewFileSubmitter returns a FileSubmitter writing to the directory, which is created when the
+// first event is written.
+func NewFileSubmitter(dir string, opts FileOptions) (*FileSubmitter, error) {
+	if dir == "" {
+		return nil, errs.Errorf("file submitter requires a directory")
+	}
+	if opts.Format == "" {
+		opts.Format = FileNative
+	}
+	ext, ok := fileExtensions[opts.Format]
+	if !ok {
+		return nil, errs.Errorf("unknown file format %q", opts.Format)
+	}
+	switch opts.Sync {
+	case "":
+		opts.Sync = SyncRotate
+	case SyncNever, SyncRotate, SyncFlush, SyncAlways:
+	default:
+		return nil, errs.Errorf("unknown file sync policy %q", opts.Sync)
+	}
+	if opts.RotateSize <= 0 {
+		opts.RotateSize = defaultFileRotateSize
+	}
+	if opts.RotateInterval <= 0 {
+		opts.RotateInterval = defaultFileRotateInterval
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFileFlushInterval
+	}
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = defaultFileBatch
+	}
+
+	return &FileSubmitter{
+		dir:     dir,
+		opts:    opts,
+		ext:     ext,
+		live:    newLiveBuffer(),
+		queue:   make([]hydrant.Event, 0, opts.MaxBatchSize),
+		trigger: make(chan struct{}, 1),
+	}, nil
+}
+
+func (f *FileSubmitter) Children() []Submitter {
+	return []Submitter{}
+}
+
+func (f *FileSubmitter) ExtraData() any {
+	extra := map[string]string{
+		"dir":             f.dir,
+		"format":          f.opts.Format,
+		"rotate_size":     strconv.FormatInt(f.opts.RotateSize, 10),
+		"rotate_interval": f.opts.RotateInterval.String(),
+		"compress":        strconv.FormatBool(f.opts.Compress),
+		"sync":            f.opts.Sync,
+	}
+	if f.opts.MaxSegments > 0 {
+		extra["max_segments"] = strconv.Itoa(f.opts.MaxSegments)
+	}
+	if f.opts.MaxAge > 0 {
+		extra["max_age"] = f.opts.MaxAge.String()
+	}
+	return extra
+}
+
+func (f *FileSubmitter) lockWait() *lockTimer { return &f.wait }
+
+func (f *FileSubmitter) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.mu.Lock()
+			f.writeQueued()
+			_ = f.rotate()
+			f.mu.Unlock()
+			_ = f.maintain(time.Now())
+			return
+
+		case <-f.trigger:
+			f.mu.Lock()
+			f.writeQueued()
+			f.mu.Unlock()
+
+		case now := <-ticker.C:
+			f.mu.Lock()
+			f.writeQueued()
+			if f.f != nil && now.Sub(f.opened) >= f.opts.RotateInterval {
+				_ = f.rotate()
+			} else {
+				_ = f.flush()
+			}
+			f.mu.Unlock()
+			_ = f.maintain(now)
+		}
+	}
+}
+
+// Flush writes the queued events to the current segment, syncing it with SyncFlush, and
+// compresses and removes closed segments as configured.
+func (f *FileSubmitter) Flush(ctx context.Context) error {
+	f.mu.Lock()
+	f.writeQueued()
+	err := f.flush()
+	f.mu.Unlock()
+	return errs.Combine(err, f.maintain(time.Now()))
+}
+
+func (f *FileSubmitter) Trigger() {
+	select {
+	case f.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (f *FileSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	f.live.Record(ev)
+	f.stats.received.Add(1)
+
+	f.wait.lock(&f.qmu)
+	if len(f.queue) < cap(f.queue) {
+		f.queue = append(f.queue, ev)
+	} else {
+		f.stats.dropped.Add(1)
+	}
+	// trigger a write slightly early to avoid dropping events.
+	if len(f.queue) >= cap(f.queue)*2/3 {
+		f.Trigger()
+	}
+	f.qmu.Unlock()
+}
+
+// writeQueued writes the queued events to the segments. Must be called with f.mu held.
+func (f *FileSubmitter) writeQueued() {
+	f.qmu.Lock()
+	queue := slices.Clone(f.queue)
+	clear(f.queue)
+	f.queue = f.queue[:0]
+	f.qmu.Unlock()
+
+	for _, ev := range queue {
+		if err := f.write(ev); err != nil {
+			f.stats.lost.Add(1)
+			f.stats.writeErrors.Add(1)
+			_ = f.rotate()
+			continue
+		}
+		f.stats.written.Add(1)
+
+		if f.size >= f.opts.RotateSize {
+			_ = f.rotate()
+		}
+	}
+}
+
+// write appends the event to the current segment, opening one if needed. Must be called with
+// f.mu held.
+func (f *FileSubmitter) write(ev hydrant.Event) (err error) {
+	if f.f == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	f.buf = f.buf[:0]
+	if f.opts.Format == FileJSONL {
+		f.buf, err = appendJSONEvent(f.buf, ev)
+		if err != nil {
+			return err
+		}
+	} else {
+		f.buf = appendNativeEvent(f.buf, ev)
+	}
+
+	if _, err := f.w.Write(f.buf); err != nil {
+		return err
+	}
+	f.size += int64(len(f.buf))
+	f.stats.bytesWritten.Add(uint64(len(f.buf)))
+
+	if f.opts.Sync == SyncAlways {
+		if err := f.w.Flush(); err != nil {
+			return err
+		}
+		if err := f.f.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open starts a new segment. Must be called with f.mu held.
+func (f *FileSubmitter) open() error {
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+
+	// segments opened at the same time on coarse clocks are named as if a nanosecond apart.
+	now := time.Now()
+	var path string
+	var fh *os.File
+	for {
+		path = filepath.Join(f.dir, fileSegmentPrefix+now.UTC().Format(fileSegmentTime)+f.ext)
+		var err error
+		fh, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			now = now.Add(time.Nanosecond)
+			continue
+		} else if err != nil {
+			return err
+		}
+		break
+	}
+
+	f.f, f.path, f.size, f.opened = fh, path, 0, now
+	if f.w == nil {
+		f.w = bufio.NewWriterSize(fh, 64<<10)
+	} else {
+		f.w.Reset(fh)
+	}
+	return nil
+}
+
+// flush writes the buffered events to the current segment. Must be called with f.mu held.
+func (f *FileSubmitter) flush() error {
+	if f.f == nil {
+		return nil
+	}
+	err := f.w.Flush()
+	if err == nil && f.opts.Sync == SyncFlush {
+		err = f.f.Sync()
+	}
+	if err != nil {
+		f.stats.writeErrors.Add(1)
+	}
+	return err
+}
+
+// rotate closes the current segment, if any, so that the next event starts a new one. Must be
+// called with f.mu held.
+func (f *FileSubmitter) rotate() error {
+	if f.f == nil {
+		return nil
+	}
+
+	err := f.w.Flush()
+	if err == nil && f.opts.Sync != SyncNever {
+		err = f.f.Sync()
+	}
+	err = errs.Combine(err, f.f.Close())
+	if err != nil {
+		f.stats.writeErrors.Add(1)
+	}
+
+	if f.opts.Compress {
+		f.closed = append(f.closed, f.path)
+	}
+	f.f, f.path = nil, ""
+	f.w.Reset(nil)
+	f.stats.rotations.Add(1)
+	return err
+}
+
+// maintain compresses the closed segments and removes the ones past the retention limits.
+func (f *FileSubmitter) maintain(now time.Time) error {
+	f.maint.Lock()
+	defer f.maint.Unlock()
+
+	f.mu.Lock()
+	closed := f.closed
+	f.closed = nil
+	current := f.path
+	f.mu.Unlock()
+
+	var group errs.Group
+	for _, path := range closed {
+		if err := compressSegment(path); err != nil {
+			group.Add(err)
+			continue
+		}
+		f.stats.compressed.Add(1)
+	}
+
+	if f.opts.MaxSegments <= 0 && f.opts.MaxAge <= 0 {
+		return group.Err()
+	}
+
+	segments, err := f.segments()
+	if err != nil {
+		group.Add(err)
+		return group.Err()
+	}
+	segments = slices.DeleteFunc(segments, func(path string) bool { return path == current })
+
+	for i, path := range segments {
+		remove := f.opts.MaxSegments > 0 && len(segments)-i > f.opts.MaxSegments
+		if !remove && f.opts.MaxAge > 0 {
+			if fi, err := os.Stat(path); err == nil && now.Sub(fi.ModTime()) > f.opts.MaxAge {
+				remove = true
+			}
+		}
+		if !remove {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			group.Add(err)
+			continue
+		}
+		f.stats.removed.Add(1)
+	}
+
+	return group.Err()
+}
+
+// segments returns the paths of the segments in the directory, oldest first.
+func (f *FileSubmitter) segments() ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(name, fileSegmentPrefix) &&
+			(strings.HasSuffix(name, f.ext) || strings.HasSuffix(name, f.ext+".zst")) {
+			paths = append(paths, filepath.Join(f.dir, name))
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// compressSegment replaces the segment with a zstd compressed copy with .zst appended to its name.
+func compressSegment(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	tmp := path + ".zst.tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = dst.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	enc, err := zstd.NewWriter(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(enc, src); err != nil {
+		_ = enc.Close()
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path+".zst"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (f *FileSubmitter) lostEvents() uint64 {
+	return f.stats.dropped.Load() + f.stats.lost.Load()
+}
+
+func (f *FileSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", f.stats.received.Load()},
+		{"dropped", f.stats.dropped.Load()},
+		{"written", f.stats.written.Load()},
+		{"bytes_written", f.stats.bytesWritten.Load()},
+		{"lost", f.stats.lost.Load()},
+		{"write_errors", f.stats.writeErrors.Load()},
+		{"rotations", f.stats.rotations.Load()},
+		{"compressed", f.stats.compressed.Load()},
+		{"removed", f.stats.removed.Load()},
+	}
+}
+
+func (f *FileSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(f)),
+		"/live":  f.live.Handler(),
+		"/stats": statsHandler(f.Stats),
+	}
+}
@@ -0,0 +1,117 @@
+package submitters
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/filter"
+)
+
+func TestFileSubmitter(t *testing.T) {
+	dir := t.TempDir()
+
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(fmt.Sprintf(`{
+		"submitter": {
+			"kind": "file",
+			"dir": %q,
+			"format": "jsonl",
+			"rotate_size": 100,
+			"compress": true,
+			"max_segments": 2
+		}
+	}`, dir)), &cfg))
+
+	sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.NoError(t, err)
+	fs := sub.root.(*FileSubmitter)
+
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	for i := range 5 {
+		sub.Submit(t.Context(), hydrant.Event{
+			hydrant.String("name", "op"),
+			hydrant.Int("i", int64(i)),
+			hydrant.Duration("duration", 1500*time.Millisecond),
+			hydrant.Timestamp("timestamp", ts),
+			hydrant.Float("ratio", math.Inf(1)),
+		})
+	}
+	assert.NoError(t, sub.Flush(t.Context()))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	// every event fills and closes a segment, and only the last two are kept.
+	assert.Equal(t, len(names), 2)
+	for _, name := range names {
+		assert.That(t, strings.HasPrefix(name, "events-"))
+		assert.That(t, strings.HasSuffix(name, ".jsonl.zst"))
+	}
+
+	stats := make(map[string]uint64)
+	for _, s := range fs.Stats() {
+		stats[s.Name] = s.Value
+	}
+	assert.Equal(t, stats["written"], uint64(5))
+	assert.Equal(t, stats["rotations"], uint64(5))
+	assert.Equal(t, stats["compressed"], uint64(5))
+	assert.Equal(t, stats["removed"], uint64(3))
+
+	line, err := appendJSONEvent(nil, hydrant.Event{
+		hydrant.Duration("duration", 1500*time.Millisecond),
+		hydrant.Timestamp("timestamp", ts),
+		hydrant.Float("ratio", math.Inf(1)),
+		hydrant.TraceId("trace_id", [16]byte{15: 1}),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, string(line), `[`+
+		`{"key":"duration","kind":"duration","value":"1.5s"},`+
+		`{"key":"timestamp","kind":"timestamp","value":"2026-01-02T03:04:05.000000006Z"},`+
+		`{"key":"ratio","kind":"float","value":"+Inf"},`+
+		`{"key":"trace_id","kind":"trace_id","value":"00000000000000000000000000000001"}`+
+		"]\n")
+
+	// native segments are length prefixed events.
+	ndir := t.TempDir()
+	nfs, err := NewFileSubmitter(ndir, FileOptions{Sync: SyncAlways})
+	assert.NoError(t, err)
+	ev := hydrant.Event{hydrant.String("message", "hello"), hydrant.Uint("n", 7)}
+	nfs.Submit(t.Context(), ev)
+	nfs.Submit(t.Context(), ev)
+	assert.NoError(t, nfs.Flush(t.Context()))
+
+	entries, err = os.ReadDir(ndir)
+	assert.NoError(t, err)
+	assert.Equal(t, len(entries), 1)
+	data, err := os.ReadFile(filepath.Join(ndir, entries[0].Name()))
+	assert.NoError(t, err)
+	assert.Equal(t, data, appendNativeEvent(appendNativeEvent(nil, ev), ev))
+
+	_, err = NewFileSubmitter(ndir, FileOptions{Sync: "sometimes"})
+	assert.Error(t, err)
+
+	// events past the queue are dropped until it is written.
+	qfs, err := NewFileSubmitter(t.TempDir(), FileOptions{MaxBatchSize: 1})
+	assert.NoError(t, err)
+	qfs.Submit(t.Context(), ev)
+	qfs.Submit(t.Context(), ev)
+	assert.NoError(t, qfs.Flush(t.Context()))
+	qfs.Submit(t.Context(), ev)
+	assert.NoError(t, qfs.Flush(t.Context()))
+	assert.Equal(t, qfs.stats.dropped.Load(), uint64(1))
+	assert.Equal(t, qfs.stats.written.Load(), uint64(2))
+	assert.Equal(t, qfs.lostEvents(), uint64(1))
+}
@@ -45,6 +45,8 @@ func (f *FilterSubmitter) ExtraData() any {
 	return map[string]string{"filter": f.fil.Filter()}
 }
 
+func (f *FilterSubmitter) Flush(ctx context.Context) error { return flushChildren(ctx, f) }
+
 func (f *FilterSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	f.live.Record(ev)
 	f.stats.received.Add(1)
@@ -59,17 +61,19 @@ func (f *FilterSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	filterEvalPool.Put(es)
 }
 
+func (f *FilterSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", f.stats.received.Load()},
+		{"passed", f.stats.passed.Load()},
+		{"filtered", f.stats.filtered.Load()},
+	}
+}
+
 func (f *FilterSubmitter) Handler() http.Handler {
 	return hmux.Dir{
-		"/tree": constJSONHandler(treeify(f)),
-		"/live": f.live.Handler(),
-		"/sub":  f.sub.Handler(),
-		"/stats": statsHandler(func() []stat {
-			return []stat{
-				{"received", f.stats.received.Load()},
-				{"passed", f.stats.passed.Load()},
-				{"filtered", f.stats.filtered.Load()},
-			}
-		}),
+		"/tree":  constJSONHandler(treeify(f)),
+		"/live":  f.live.Handler(),
+		"/sub":   f.sub.Handler(),
+		"/stats": statsHandler(f.Stats),
 	}
 }
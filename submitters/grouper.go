@@ -3,6 +3,7 @@ package submitters
 import (
 	"context"
 	"maps"
+	"math"
 	"net/http"
 	"slices"
 	"strings"
@@ -28,11 +29,67 @@ const (
 type groupedEvents struct {
 	event    hydrant.Event
 	groupSet map[string]struct{}
-	hists    map[string]*flathist.Histogram
+	hists    map[string]*groupedHist
 	histOrd  []string
 	excluded map[string]struct{}
 }
 
+// groupedHist is a histogram of the sampled observations of a key, along with how many
+// observations it holds and how many events they stand for once weighted. Durations are
+// observed in seconds.
+type groupedHist struct {
+	hist     *flathist.Histogram
+	observed float64
+	weighted float64
+	duration bool
+}
+
+// add records that n observations were made from events of the weight.
+func (gh *groupedHist) add(n, weight float64) {
+	gh.observed += n
+	gh.weighted += n * weight
+}
+
+// weightedHist returns the histogram with its bucket counts scaled so that its total is the
+// weighted number of observations.
+func (gh *groupedHist) weightedHist() *flathist.Histogram {
+	if gh.observed == 0 || gh.weighted == gh.observed {
+		return gh.hist
+	}
+	return scaleHistogram(gh.hist, gh.weighted/gh.observed)
+}
+
+// scaleHistogram returns a histogram with the count of every bucket of h multiplied by scale.
+// The counts are rounded cumulatively so the total is the rounded scaled total.
+func scaleHistogram(h *flathist.Histogram, scale float64) *flathist.Histogram {
+	out := flathist.NewHistogram()
+	var prev uint64
+	h.Distribution(func(value float32, count, total uint64) {
+		next := uint64(math.Round(float64(count) * scale))
+		if next > prev {
+			observeN(out, value, next-prev)
+			prev = next
+		}
+	})
+	return out
+}
+
+// observeN observes the value n times into h, doubling a histogram of the value so that it
+// takes a logarithmic number of merges.
+func observeN(h *flathist.Histogram, value float32, n uint64) {
+	unit := flathist.NewHistogram()
+	unit.Observe(value)
+	for {
+		if n&1 == 1 {
+			h.Merge(unit)
+		}
+		if n >>= 1; n == 0 {
+			return
+		}
+		unit.Merge(unit.Clone())
+	}
+}
+
 type GrouperSubmitter struct {
 	grouper  *group.Grouper
 	sub      Submitter
@@ -46,7 +103,9 @@ type GrouperSubmitter struct {
 		groupsFlushed atomic.Uint64
 	}
 
+	wait   lockTimer
 	mu     sync.Mutex
+	start  time.Time
 	groups map[unique.Handle[string]]*groupedEvents
 }
 
@@ -61,6 +120,7 @@ func NewGrouperSubmitter(
 		interval: utils.Bound(interval, [2]time.Duration{minGroupInterval, maxGroupInterval}),
 		live:     newLiveBuffer(),
 
+		start:  time.Now(),
 		groups: make(map[unique.Handle[string]]*groupedEvents),
 	}
 }
@@ -71,21 +131,30 @@ func (g *GrouperSubmitter) Children() []Submitter {
 
 func (g *GrouperSubmitter) ExtraData() any { return nil }
 
-func (g *GrouperSubmitter) Run(ctx context.Context) {
-	start := time.Now()
+func (g *GrouperSubmitter) lockWait() *lockTimer { return &g.wait }
 
+func (g *GrouperSubmitter) Run(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			start = g.flush(context.WithoutCancel(ctx), start)
+			ctx, cancel := drainContext(ctx)
+			defer cancel()
+			g.flush(ctx)
 			return
 
 		case <-time.After(g.interval):
-			start = g.flush(ctx, start)
+			g.flush(ctx)
 		}
 	}
 }
 
+// Flush emits the current groups, ending the aggregation period early, and then flushes the
+// child submitter.
+func (g *GrouperSubmitter) Flush(ctx context.Context) error {
+	g.flush(ctx)
+	return flushChildren(ctx, g)
+}
+
 func (g *GrouperSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	g.live.Record(ev)
 	g.stats.received.Add(1)
@@ -99,26 +168,40 @@ func (g *GrouperSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	// TODO: it'd be nice if this mutex was smaller or non-existent but we have to coordinate with
 	// the flush call. perhaps we can swaparoo it. we would also either need to add a mutex to the
 	// groupedEvents or make it use sync maps or something. the histograms are already concurrent.
-	g.mu.Lock()
+	g.wait.lock(&g.mu)
 	defer g.mu.Unlock()
 
 	ge := g.groups[key]
 	if ge == nil {
-		group := g.grouper.Annotations(ev)
-		groupSet := make(map[string]struct{}, len(group))
-		for _, ann := range group {
-			groupSet[ann.Key] = struct{}{}
-		}
+		ge = newGroupedEvents(g.grouper.Annotations(ev))
+		g.groups[key] = ge
+	}
 
-		ge = &groupedEvents{
-			event:    group,
-			groupSet: groupSet,
-			hists:    make(map[string]*flathist.Histogram),
-			excluded: make(map[string]struct{}),
-		}
+	ge.observe(ev)
+}
 
-		g.groups[key] = ge
+func newGroupedEvents(group hydrant.Event) *groupedEvents {
+	groupSet := make(map[string]struct{}, len(group))
+	for _, ann := range group {
+		groupSet[ann.Key] = struct{}{}
+	}
+
+	return &groupedEvents{
+		event:    group,
+		groupSet: groupSet,
+		hists:    make(map[string]*groupedHist),
+		excluded: make(map[string]struct{}),
 	}
+}
+
+// observe adds the annotations of the event to the histograms of the group.
+func (ge *groupedEvents) observe(ev hydrant.Event) {
+	// sampled events are observed once, and the histograms are scaled by the weights of their
+	// observations when the group is flushed.
+	weight := eventWeight(ev)
+
+	// histograms from grouped events keep whether they hold durations.
+	durations := aggDurations(ev)
 
 	for _, ann := range ev {
 		// annotations in the group set are not included
@@ -126,16 +209,19 @@ func (g *GrouperSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 			continue
 		}
 
+		// neither are the weights, which are applied to the histograms
+		if isWeightKey(ann.Key) {
+			continue
+		}
+
 		// if we got a full histogram, merge it into our existing one.
 		if h, ok := ann.Value.Histogram(); ok {
-			into, ok := ge.hists[ann.Key]
-			if !ok {
-				into = flathist.NewHistogram()
-				ge.hists[ann.Key] = into
-				ge.histOrd = append(ge.histOrd, ann.Key)
+			into := ge.hist(ann.Key)
+			into.hist.Merge(h)
+			into.add(float64(h.Total()), weight)
+			if _, ok := durations[ann.Key]; ok {
+				into.duration = true
 			}
-
-			into.Merge(h)
 			continue
 		}
 
@@ -147,15 +233,41 @@ func (g *GrouperSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 		}
 
 		// otherwise, observe the value.
-		into, ok := ge.hists[ann.Key]
-		if !ok {
-			into = flathist.NewHistogram()
-			ge.hists[ann.Key] = into
-			ge.histOrd = append(ge.histOrd, ann.Key)
+		into := ge.hist(ann.Key)
+		into.hist.Observe(datum)
+		into.add(1, weight)
+		if ann.Value.Kind() == value.KindDuration {
+			into.duration = true
+		}
+	}
+}
+
+// aggDurations returns the keys of the histograms of a grouped event that hold durations in
+// seconds, from its agg:durations annotation.
+func aggDurations(ev hydrant.Event) map[string]struct{} {
+	for _, ann := range ev {
+		if ann.Key != "agg:durations" {
+			continue
+		}
+		keys, _ := ann.Value.String()
+		durations := make(map[string]struct{})
+		for key := range strings.SplitSeq(keys, ",") {
+			durations[key] = struct{}{}
 		}
+		return durations
+	}
+	return nil
+}
 
-		into.Observe(datum)
+// hist returns the histogram for the key, creating it if necessary.
+func (ge *groupedEvents) hist(key string) *groupedHist {
+	gh, ok := ge.hists[key]
+	if !ok {
+		gh = &groupedHist{hist: flathist.NewHistogram()}
+		ge.hists[key] = gh
+		ge.histOrd = append(ge.histOrd, key)
 	}
+	return gh
 }
 
 func observableValue(v value.Value) (float32, bool) {
@@ -182,51 +294,73 @@ func observableValue(v value.Value) (float32, bool) {
 	return 0, false
 }
 
-func (g *GrouperSubmitter) flush(ctx context.Context, start time.Time) time.Time {
+func (g *GrouperSubmitter) flush(ctx context.Context) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	end := time.Now()
+	start, end := g.start, time.Now()
+	g.start = end
 
 	g.stats.flushes.Add(1)
 	g.stats.groupsFlushed.Add(uint64(len(g.groups)))
 
 	for _, ge := range g.groups {
-		ge.event = append(ge.event,
-			hydrant.Timestamp("agg:start_time", start),
-			hydrant.Timestamp("agg:end_time", end),
-			hydrant.Duration("agg:duration", end.Sub(start)),
-		)
-		if len(ge.excluded) > 0 {
-			excluded := strings.Join(slices.Collect(maps.Keys(ge.excluded)), ",")
-			ge.event = append(ge.event,
-				hydrant.String("agg:excluded", excluded),
-			)
-		}
-		for _, key := range ge.histOrd {
-			ge.event = append(ge.event, hydrant.Histogram(key, ge.hists[key]))
-		}
-
-		g.sub.Submit(ctx, ge.event)
+		g.sub.Submit(ctx, ge.aggregate(start, end))
 	}
 
 	clear(g.groups)
+}
+
+// aggregate returns the grouped event for the period from start to end: the group annotations,
+// the agg metadata and the weighted histograms. The keys of the histograms of durations are
+// listed in agg:durations.
+func (ge *groupedEvents) aggregate(start, end time.Time) hydrant.Event {
+	ev := append(ge.event,
+		hydrant.Timestamp("agg:start_time", start),
+		hydrant.Timestamp("agg:end_time", end),
+		hydrant.Duration("agg:duration", end.Sub(start)),
+	)
+	if len(ge.excluded) > 0 {
+		excluded := strings.Join(slices.Collect(maps.Keys(ge.excluded)), ",")
+		ev = append(ev,
+			hydrant.String("agg:excluded", excluded),
+		)
+	}
+	var durations []string
+	for _, key := range ge.histOrd {
+		if ge.hists[key].duration {
+			durations = append(durations, key)
+		}
+	}
+	if len(durations) > 0 {
+		ev = append(ev,
+			hydrant.String("agg:durations", strings.Join(durations, ",")),
+		)
+	}
+	for _, key := range ge.histOrd {
+		ev = append(ev, hydrant.Histogram(key, ge.hists[key].weightedHist()))
+	}
+	return ev
+}
 
-	return end
+func (g *GrouperSubmitter) Stats() []Stat {
+	g.mu.Lock()
+	groupsActive := uint64(len(g.groups))
+	g.mu.Unlock()
+	return []Stat{
+		{"received", g.stats.received.Load()},
+		{"ungroupable", g.stats.ungroupable.Load()},
+		{"flushes", g.stats.flushes.Load()},
+		{"groups_flushed", g.stats.groupsFlushed.Load()},
+		{"groups_active", groupsActive},
+	}
 }
 
 func (g *GrouperSubmitter) Handler() http.Handler {
 	return hmux.Dir{
-		"/tree": constJSONHandler(treeify(g)),
-		"/live": g.live.Handler(),
-		"/sub":  g.sub.Handler(),
-		"/stats": statsHandler(func() []stat {
-			return []stat{
-				{"received", g.stats.received.Load()},
-				{"ungroupable", g.stats.ungroupable.Load()},
-				{"flushes", g.stats.flushes.Load()},
-				{"groups_flushed", g.stats.groupsFlushed.Load()},
-			}
-		}),
+		"/tree":  constJSONHandler(treeify(g)),
+		"/live":  g.live.Handler(),
+		"/sub":   g.sub.Handler(),
+		"/stats": statsHandler(g.Stats),
 	}
 }
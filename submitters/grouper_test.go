@@ -0,0 +1,75 @@
+package submitters
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"github.com/histdb/histdb/flathist"
+	"storj.io/hydrant"
+)
+
+func TestWeightedCounts(t *testing.T) {
+	prom := NewPrometheusSubmitter("", nil)
+	grp := NewGrouperSubmitter([]string{"name"}, time.Minute, prom)
+
+	for range 3 {
+		grp.Submit(t.Context(), hydrant.Event{
+			hydrant.String("name", "a"),
+			hydrant.Duration("duration", time.Second),
+			hydrant.Float("sample_rate", 4),
+		})
+	}
+
+	// huge weights cost no more than any other event.
+	grp.Submit(t.Context(), hydrant.Event{
+		hydrant.String("name", "c"),
+		hydrant.Duration("duration", time.Second),
+		hydrant.Float("_weight", 1e9),
+	})
+	assert.NoError(t, grp.Flush(t.Context()))
+
+	// a full histogram with a fractional weight is scaled exactly.
+	h := flathist.NewHistogram()
+	h.Observe(1)
+	h.Observe(2)
+	prom.Submit(t.Context(), hydrant.Event{
+		hydrant.String("name", "b"),
+		hydrant.Histogram("duration", h),
+		hydrant.Float("_weight", 2.5),
+	})
+
+	prom.mu.Lock()
+	defer prom.mu.Unlock()
+	// histogram sums are approximate.
+	assert.Equal(t, prom.series["name=a"].count, 12.)
+	assert.That(t, math.Abs(prom.series["name=a"].sum-12) < 0.1)
+	assert.Equal(t, prom.series["name=b"].count, 5.)
+	assert.That(t, math.Abs(prom.series["name=b"].sum-7.5) < 0.1)
+	assert.Equal(t, prom.series["name=c"].count, 1e9)
+
+	// the grouped histograms themselves are weighted, so every submitter below sees the counts.
+	hyd := NewHydratorSubmitter()
+	grp = NewGrouperSubmitter([]string{"name"}, time.Minute, hyd)
+	for i := range 4 {
+		grp.Submit(t.Context(), hydrant.Event{
+			hydrant.String("name", "a"),
+			hydrant.Duration("duration", time.Duration(i+1)*time.Second),
+			hydrant.Float("sample_rate", 10),
+		})
+	}
+	grp.Submit(t.Context(), hydrant.Event{
+		hydrant.String("name", "a"),
+		hydrant.Duration("duration", 5*time.Second),
+	})
+	assert.NoError(t, grp.Flush(t.Context()))
+
+	var total uint64
+	assert.NoError(t, hyd.Query([]byte(`_=duration`), func(name []byte, h *flathist.Histogram) bool {
+		total += h.Total()
+		return true
+	}))
+	assert.Equal(t, total, uint64(41))
+}
@@ -11,6 +11,11 @@ func treeify(sub Submitter) any {
 	if sub, ok := sub.(*lateSubmitter); ok {
 		return treeify(sub.sub)
 	}
+	if sub, ok := sub.(*profiledSubmitter); ok {
+		tree := treeify(sub.sub).(map[string]any)
+		tree["profile"] = sub.profile()
+		return tree
+	}
 	var children []any
 	for _, child := range sub.Children() {
 		children = append(children, treeify(child))
@@ -33,12 +38,13 @@ func constJSONHandler(value any) http.Handler {
 	})
 }
 
-type stat struct {
+// Stat is a named counter or gauge reported by a Submitter.
+type Stat struct {
 	Name  string `json:"name"`
 	Value uint64 `json:"value"`
 }
 
-func statsHandler(fn func() []stat) http.Handler {
+func statsHandler(fn func() []Stat) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(fn())
@@ -10,9 +10,11 @@ import (
 	"time"
 
 	"github.com/klauspost/compress/zstd"
+	"github.com/zeebo/errs/v2"
 	"github.com/zeebo/hmux"
 
 	"storj.io/hydrant"
+	"storj.io/hydrant/config"
 	"storj.io/hydrant/internal/rw"
 	"storj.io/hydrant/internal/utils"
 )
@@ -29,6 +31,7 @@ type HTTPSubmitter struct {
 		dropped     atomic.Uint64
 		flushes     atomic.Uint64
 		flushErrors atomic.Uint64
+		lost        atomic.Uint64
 		bytesSent   atomic.Uint64
 	}
 
@@ -68,7 +71,7 @@ func (h *HTTPSubmitter) Children() []Submitter {
 }
 
 func (h *HTTPSubmitter) ExtraData() any {
-	return map[string]string{"endpoint": h.url}
+	return map[string]string{"endpoint": config.Redact(h.url)}
 }
 
 func (h *HTTPSubmitter) Run(ctx context.Context) {
@@ -76,17 +79,20 @@ func (h *HTTPSubmitter) Run(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			// TODO: maybe we want to put a timeout or not flush at all? unsure
-			h.flush(context.WithoutCancel(ctx))
+			ctx, cancel := drainContext(ctx)
+			defer cancel()
+			_ = h.flush(ctx)
 			return
 		case <-h.trigger:
 		case <-nextTick:
 		}
 		nextTick = time.After(utils.Jitter(h.interval))
-		h.flush(ctx)
+		_ = h.flush(ctx)
 	}
 }
 
+func (h *HTTPSubmitter) Flush(ctx context.Context) error { return h.flush(ctx) }
+
 func (h *HTTPSubmitter) Trigger() {
 	select {
 	case h.trigger <- struct{}{}:
@@ -111,16 +117,24 @@ func (h *HTTPSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	h.mu.Unlock()
 }
 
-func (h *HTTPSubmitter) flush(ctx context.Context) {
+// flush sends the current batch. If it fails, the events in the batch are lost.
+func (h *HTTPSubmitter) flush(ctx context.Context) (err error) {
 	h.mu.Lock()
 	batch := slices.Clone(h.batch)
 	h.batch = h.batch[:0]
 	h.mu.Unlock()
 
 	if len(batch) == 0 {
-		return
+		return nil
 	}
 
+	defer func() {
+		if err != nil {
+			h.stats.flushErrors.Add(1)
+			h.stats.lost.Add(uint64(len(batch)))
+		}
+	}()
+
 	buf := make([]byte, 0, 64)
 	buf = hydrant.Event(h.process).AppendTo(buf)
 	buf = rw.AppendVarint(buf, uint64(len(batch)))
@@ -131,37 +145,43 @@ func (h *HTTPSubmitter) flush(ctx context.Context) {
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(out))
 	if err != nil {
-		h.stats.flushErrors.Add(1)
-		return
+		return err
 	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		h.stats.flushErrors.Add(1)
-		return
+		return err
 	}
 	resp.Body.Close()
 
 	if resp.StatusCode/100 != 2 {
-		h.stats.flushErrors.Add(1)
-	} else {
-		h.stats.flushes.Add(1)
-		h.stats.bytesSent.Add(uint64(len(out)))
+		return errs.Errorf("unexpected status code from %q: %d", h.url, resp.StatusCode)
+	}
+
+	h.stats.flushes.Add(1)
+	h.stats.bytesSent.Add(uint64(len(out)))
+	return nil
+}
+
+func (h *HTTPSubmitter) lostEvents() uint64 {
+	return h.stats.dropped.Load() + h.stats.lost.Load()
+}
+
+func (h *HTTPSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", h.stats.received.Load()},
+		{"dropped", h.stats.dropped.Load()},
+		{"flushes", h.stats.flushes.Load()},
+		{"flush_errors", h.stats.flushErrors.Load()},
+		{"lost", h.stats.lost.Load()},
+		{"bytes_sent", h.stats.bytesSent.Load()},
 	}
 }
 
 func (h *HTTPSubmitter) Handler() http.Handler {
 	return hmux.Dir{
-		"/tree": constJSONHandler(treeify(h)),
-		"/live": h.live.Handler(),
-		"/stats": statsHandler(func() []stat {
-			return []stat{
-				{"received", h.stats.received.Load()},
-				{"dropped", h.stats.dropped.Load()},
-				{"flushes", h.stats.flushes.Load()},
-				{"flush_errors", h.stats.flushErrors.Load()},
-				{"bytes_sent", h.stats.bytesSent.Load()},
-			}
-		}),
+		"/tree":  constJSONHandler(treeify(h)),
+		"/live":  h.live.Handler(),
+		"/stats": statsHandler(h.Stats),
 	}
 }
@@ -27,6 +27,7 @@ type HydratorSubmitter struct {
 		received atomic.Uint64
 	}
 
+	wait  lockTimer
 	mu    sync.Mutex
 	idx   memindex.T
 	hists []*flathist.Histogram
@@ -42,6 +43,10 @@ func (h *HydratorSubmitter) Children() []Submitter {
 
 func (h *HydratorSubmitter) ExtraData() any { return nil }
 
+func (h *HydratorSubmitter) lockWait() *lockTimer { return &h.wait }
+
+func (h *HydratorSubmitter) Flush(ctx context.Context) error { return nil }
+
 var hydratorSkipKinds = [...]bool{
 	value.KindTraceId:   true,
 	value.KindSpanId:    true,
@@ -106,7 +111,7 @@ func (h *HydratorSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	if !hasHist {
 		metric := append(buf, '_')
 
-		h.mu.Lock()
+		h.wait.lock(&h.mu)
 		_, id, _, created := h.idx.Add(metric, nil, nil)
 		if created {
 			h.hists = append(h.hists, flathist.NewHistogram())
@@ -127,7 +132,7 @@ func (h *HydratorSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 		}
 		metric := append(buf, ann.Key...)
 
-		h.mu.Lock()
+		h.wait.lock(&h.mu)
 		_, id, _, created := h.idx.Add(metric, nil, nil)
 		if created {
 			h.hists = append(h.hists, flathist.NewHistogram())
@@ -165,20 +170,22 @@ func (h *HydratorSubmitter) Query(q []byte, cb func(name []byte, hist *flathist.
 	return nil
 }
 
+func (h *HydratorSubmitter) Stats() []Stat {
+	h.mu.Lock()
+	metricsStored := uint64(len(h.hists))
+	h.mu.Unlock()
+	return []Stat{
+		{"received", h.stats.received.Load()},
+		{"metrics_stored", metricsStored},
+	}
+}
+
 func (h *HydratorSubmitter) Handler() http.Handler {
 	return hmux.Dir{
 		"/tree":  constJSONHandler(treeify(h)),
 		"/live":  h.live.Handler(),
 		"/query": http.HandlerFunc(h.queryHandler),
-		"/stats": statsHandler(func() []stat {
-			h.mu.Lock()
-			metricsStored := uint64(len(h.hists))
-			h.mu.Unlock()
-			return []stat{
-				{"received", h.stats.received.Load()},
-				{"metrics_stored", metricsStored},
-			}
-		}),
+		"/stats": statsHandler(h.Stats),
 	}
 }
 
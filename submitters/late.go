@@ -8,11 +8,12 @@ import (
 )
 
 type lateSubmitter struct {
-	sub Submitter
+	name string
+	sub  Submitter
 }
 
-func newLateSubmitter() *lateSubmitter {
-	return &lateSubmitter{}
+func newLateSubmitter(name string) *lateSubmitter {
+	return &lateSubmitter{name: name}
 }
 
 func (l *lateSubmitter) SetSubmitter(sub Submitter) {
@@ -25,6 +26,10 @@ func (l *lateSubmitter) Children() []Submitter {
 
 func (l *lateSubmitter) ExtraData() any { return l.sub.ExtraData() }
 
+func (l *lateSubmitter) Stats() []Stat { return l.sub.Stats() }
+
+func (l *lateSubmitter) Flush(ctx context.Context) error { return l.sub.Flush(ctx) }
+
 func (l *lateSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	l.sub.Submit(ctx, ev)
 }
@@ -3,9 +3,14 @@ package submitters
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 
 	"storj.io/hydrant"
+	"storj.io/hydrant/filter"
 	"storj.io/hydrant/internal/utils"
 )
 
@@ -13,12 +18,27 @@ const liveBufferSize = 128
 
 type liveBuffer struct {
 	buf *utils.RingBuffer[hydrant.Event]
+	env *filter.Environment
 }
 
 func newLiveBuffer() liveBuffer {
 	return liveBuffer{buf: utils.NewRingBuffer[hydrant.Event](liveBufferSize)}
 }
 
+// configure sets the number of events kept and the environment used to parse the filters passed
+// to the handler. It must be called before the submitter is used unless nothing changes.
+func (l *liveBuffer) configure(size int, env *filter.Environment) {
+	if size <= 0 {
+		size = liveBufferSize
+	}
+	if size != l.buf.Cap() {
+		l.buf = utils.NewRingBuffer[hydrant.Event](size)
+	}
+	if l.env != env {
+		l.env = env
+	}
+}
+
 func (l *liveBuffer) Record(ev hydrant.Event) {
 	l.buf.Add(ev)
 }
@@ -33,8 +53,32 @@ func (l *liveBuffer) Handler() http.Handler {
 	})
 }
 
+// parseFilter parses the filter query parameter. A nil filter passes every event.
+func (l *liveBuffer) parseFilter(r *http.Request) (*filter.Filter, error) {
+	expr := r.URL.Query().Get("filter")
+	if expr == "" {
+		return nil, nil
+	}
+	env := l.env
+	if env == nil {
+		env = filter.NewBuiltinEnvionment()
+	}
+	return env.Parse(expr)
+}
+
 func (l *liveBuffer) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	fil, err := l.parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	events := l.buf.Get()
+	if fil != nil {
+		var es filter.EvalState
+		events = slices.DeleteFunc(events, func(ev hydrant.Event) bool { return !es.Evaluate(fil, ev) })
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(serializeEvents(events))
 }
@@ -46,12 +90,36 @@ func (l *liveBuffer) handleWatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fil, err := l.parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var limit tokenBucket
+	if v := r.URL.Query().Get("rate"); v != "" {
+		limit.rate, err = strconv.ParseFloat(v, 64)
+		// a zero rate would mean no limit at all, so only positive finite rates are accepted.
+		if err != nil || !(limit.rate > 0) || math.IsInf(limit.rate, 0) {
+			http.Error(w, fmt.Sprintf("invalid rate %q", v), http.StatusBadRequest)
+			return
+		}
+		limit.burst = max(limit.rate, 1)
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	flusher.Flush()
 
+	var es filter.EvalState
 	l.buf.Watch(r.Context(), func(ev hydrant.Event) {
+		if fil != nil && !es.Evaluate(fil, ev) {
+			return
+		}
+		if !limit.allow(time.Now()) {
+			return
+		}
 		data, err := json.Marshal(serializeEvent(ev))
 		if err != nil {
 			return
@@ -0,0 +1,44 @@
+package submitters
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/filter"
+)
+
+func TestLiveFilter(t *testing.T) {
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"submitter": {"kind": "null", "live_buffer_size": 4}
+	}`), &cfg))
+
+	sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.NoError(t, err)
+
+	for i := range 10 {
+		sub.Submit(t.Context(), hydrant.Event{hydrant.Int("i", int64(i))})
+	}
+
+	live := func(query string) (events [][]map[string]any) {
+		rec := httptest.NewRecorder()
+		sub.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/sub/live"+query, nil))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &events))
+		return events
+	}
+	assert.Equal(t, len(live("")), 4)
+	assert.Equal(t, len(live("?filter=gte(key(i),8)")), 2)
+
+	for _, rate := range []string{"x", "0", "-1", "NaN", "Inf"} {
+		rec := httptest.NewRecorder()
+		sub.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/sub/live?watch=1&rate="+rate, nil))
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	}
+}
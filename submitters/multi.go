@@ -35,6 +35,8 @@ func (m *MultiSubmitter) Children() []Submitter {
 
 func (m *MultiSubmitter) ExtraData() any { return nil }
 
+func (m *MultiSubmitter) Flush(ctx context.Context) error { return flushChildren(ctx, m) }
+
 func (m *MultiSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	m.live.Record(ev)
 	m.stats.received.Add(1)
@@ -44,6 +46,12 @@ func (m *MultiSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	}
 }
 
+func (m *MultiSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", m.stats.received.Load()},
+	}
+}
+
 func (m *MultiSubmitter) Handler() http.Handler {
 	subs := hmux.Dir{}
 	for i, sub := range m.subs {
@@ -51,13 +59,9 @@ func (m *MultiSubmitter) Handler() http.Handler {
 	}
 
 	return hmux.Dir{
-		"/tree": constJSONHandler(treeify(m)),
-		"/live": m.live.Handler(),
-		"/sub":  subs,
-		"/stats": statsHandler(func() []stat {
-			return []stat{
-				{"received", m.stats.received.Load()},
-			}
-		}),
+		"/tree":  constJSONHandler(treeify(m)),
+		"/live":  m.live.Handler(),
+		"/sub":   subs,
+		"/stats": statsHandler(m.Stats),
 	}
 }
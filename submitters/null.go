@@ -28,19 +28,23 @@ func (n *NullSubmitter) Children() []Submitter {
 
 func (n *NullSubmitter) ExtraData() any { return nil }
 
+func (n *NullSubmitter) Flush(ctx context.Context) error { return nil }
+
 func (n *NullSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	n.live.Record(ev)
 	n.stats.received.Add(1)
 }
 
+func (n *NullSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", n.stats.received.Load()},
+	}
+}
+
 func (n *NullSubmitter) Handler() http.Handler {
 	return hmux.Dir{
-		"/tree": constJSONHandler(treeify(n)),
-		"/live": n.live.Handler(),
-		"/stats": statsHandler(func() []stat {
-			return []stat{
-				{"received", n.stats.received.Load()},
-			}
-		}),
+		"/tree":  constJSONHandler(treeify(n)),
+		"/live":  n.live.Handler(),
+		"/stats": statsHandler(n.Stats),
 	}
 }
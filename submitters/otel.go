@@ -21,6 +21,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"storj.io/hydrant"
+	"storj.io/hydrant/config"
 	"storj.io/hydrant/internal/utils"
 	"storj.io/hydrant/utils/otelutil"
 	"storj.io/hydrant/value"
@@ -41,6 +42,7 @@ type OTelSubmitter struct {
 		logsDropped  atomic.Uint64
 		flushes      atomic.Uint64
 		flushErrors  atomic.Uint64
+		lost         atomic.Uint64
 	}
 
 	mu      sync.Mutex
@@ -81,7 +83,7 @@ func (o *OTelSubmitter) Children() []Submitter {
 }
 
 func (o *OTelSubmitter) ExtraData() any {
-	return map[string]string{"endpoint": o.tracesURL}
+	return map[string]string{"endpoint": config.Redact(o.tracesURL)}
 }
 
 func (o *OTelSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
@@ -118,17 +120,21 @@ func (o *OTelSubmitter) Run(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			o.flush(context.WithoutCancel(ctx))
+			ctx, cancel := drainContext(ctx)
+			defer cancel()
+			_ = o.flush(ctx)
 			return
 		case <-o.trigger:
 		case <-nextTick:
 		}
 		nextTick = time.After(utils.Jitter(o.interval))
-		o.flush(ctx)
+		_ = o.flush(ctx)
 	}
 }
 
-func (o *OTelSubmitter) flush(ctx context.Context) {
+func (o *OTelSubmitter) Flush(ctx context.Context) error { return o.flush(ctx) }
+
+func (o *OTelSubmitter) flush(ctx context.Context) error {
 	o.mu.Lock()
 	spans := slices.Clone(o.spans)
 	o.spans = o.spans[:0]
@@ -136,77 +142,88 @@ func (o *OTelSubmitter) flush(ctx context.Context) {
 	o.logs = o.logs[:0]
 	o.mu.Unlock()
 
+	var group errs.Group
 	if len(spans) > 0 {
-		o.flushSpans(ctx, spans)
+		group.Append(o.export(ctx, o.tracesURL, len(spans), func() proto.Message {
+			return spansRequest(o.resource, spans)
+		}))
 	}
 	if len(logs) > 0 {
-		o.flushLogs(ctx, logs)
+		group.Append(o.export(ctx, o.logsURL, len(logs), func() proto.Message {
+			return logsRequest(o.resource, logs)
+		}))
+	}
+	return group.Err()
+}
+
+// export posts the request to url. If it fails, the n events in the request are lost.
+func (o *OTelSubmitter) export(ctx context.Context, url string, n int, req func() proto.Message) (err error) {
+	defer func() {
+		if err != nil {
+			o.stats.flushErrors.Add(1)
+			o.stats.lost.Add(uint64(n))
+		}
+	}()
+
+	data, err := proto.Marshal(req())
+	if err != nil {
+		return err
+	}
+	if err := otelPost(ctx, url, data); err != nil {
+		return err
 	}
+	o.stats.flushes.Add(1)
+	return nil
 }
 
-func (o *OTelSubmitter) flushSpans(ctx context.Context, events []hydrant.Event) {
+func (o *OTelSubmitter) lostEvents() uint64 {
+	return o.stats.spansDropped.Load() + o.stats.logsDropped.Load() + o.stats.lost.Load()
+}
+
+func spansRequest(resource *resourcepb.Resource, events []hydrant.Event) *colltracepb.ExportTraceServiceRequest {
 	otelSpans := make([]*tracepb.Span, 0, len(events))
 	for _, ev := range events {
 		otelSpans = append(otelSpans, eventToOTelSpan(ev))
 	}
 
-	req := &colltracepb.ExportTraceServiceRequest{
+	return &colltracepb.ExportTraceServiceRequest{
 		ResourceSpans: []*tracepb.ResourceSpans{{
-			Resource:   o.resource,
+			Resource:   resource,
 			ScopeSpans: []*tracepb.ScopeSpans{{Spans: otelSpans}},
 		}},
 	}
-
-	data, err := proto.Marshal(req)
-	if err != nil {
-		o.stats.flushErrors.Add(1)
-		return
-	}
-	if err := otelPost(ctx, o.tracesURL, data); err != nil {
-		o.stats.flushErrors.Add(1)
-		return
-	}
-	o.stats.flushes.Add(1)
 }
 
-func (o *OTelSubmitter) flushLogs(ctx context.Context, events []hydrant.Event) {
+func logsRequest(resource *resourcepb.Resource, events []hydrant.Event) *colllogspb.ExportLogsServiceRequest {
 	records := make([]*logspb.LogRecord, 0, len(events))
 	for _, ev := range events {
 		records = append(records, eventToOTelLogRecord(ev))
 	}
 
-	req := &colllogspb.ExportLogsServiceRequest{
+	return &colllogspb.ExportLogsServiceRequest{
 		ResourceLogs: []*logspb.ResourceLogs{{
-			Resource:  o.resource,
+			Resource:  resource,
 			ScopeLogs: []*logspb.ScopeLogs{{LogRecords: records}},
 		}},
 	}
+}
 
-	data, err := proto.Marshal(req)
-	if err != nil {
-		o.stats.flushErrors.Add(1)
-		return
+func (o *OTelSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", o.stats.received.Load()},
+		{"spans_dropped", o.stats.spansDropped.Load()},
+		{"logs_dropped", o.stats.logsDropped.Load()},
+		{"flushes", o.stats.flushes.Load()},
+		{"flush_errors", o.stats.flushErrors.Load()},
+		{"lost", o.stats.lost.Load()},
 	}
-	if err := otelPost(ctx, o.logsURL, data); err != nil {
-		o.stats.flushErrors.Add(1)
-		return
-	}
-	o.stats.flushes.Add(1)
 }
 
 func (o *OTelSubmitter) Handler() http.Handler {
 	return hmux.Dir{
-		"/tree": constJSONHandler(treeify(o)),
-		"/live": o.live.Handler(),
-		"/stats": statsHandler(func() []stat {
-			return []stat{
-				{"received", o.stats.received.Load()},
-				{"spans_dropped", o.stats.spansDropped.Load()},
-				{"logs_dropped", o.stats.logsDropped.Load()},
-				{"flushes", o.stats.flushes.Load()},
-				{"flush_errors", o.stats.flushErrors.Load()},
-			}
-		}),
+		"/tree":  constJSONHandler(treeify(o)),
+		"/live":  o.live.Handler(),
+		"/stats": statsHandler(o.Stats),
 	}
 }
 
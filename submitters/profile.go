@@ -0,0 +1,115 @@
+package submitters
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/histdb/histdb/flathist"
+	"storj.io/hydrant"
+)
+
+// profiledSubmitter wraps a submitter and measures the wall time of its Submit calls, including
+// the time spent in its children. It is inserted around every node of the tree when the config
+// enables profiling.
+type profiledSubmitter struct {
+	sub    Submitter
+	submit *flathist.Histogram
+}
+
+func newProfiledSubmitter(sub Submitter) *profiledSubmitter {
+	return &profiledSubmitter{
+		sub:    sub,
+		submit: flathist.NewHistogram(),
+	}
+}
+
+func (p *profiledSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	start := time.Now()
+	p.sub.Submit(ctx, ev)
+	p.submit.Observe(float32(time.Since(start).Seconds()))
+}
+
+func (p *profiledSubmitter) Flush(ctx context.Context) error { return p.sub.Flush(ctx) }
+func (p *profiledSubmitter) Stats() []Stat                   { return p.sub.Stats() }
+func (p *profiledSubmitter) Handler() http.Handler           { return p.sub.Handler() }
+func (p *profiledSubmitter) Children() []Submitter           { return p.sub.Children() }
+func (p *profiledSubmitter) ExtraData() any                  { return p.sub.ExtraData() }
+
+// profile returns summaries of the submit time and, if the submitter measures it, lock wait time.
+func (p *profiledSubmitter) profile() map[string]latencySummary {
+	out := map[string]latencySummary{"submit": summarizeLatency(p.submit)}
+	if lt, ok := p.sub.(interface{ lockWait() *lockTimer }); ok {
+		if h := lt.lockWait().hist.Load(); h != nil {
+			out["lock_wait"] = summarizeLatency(h)
+		}
+	}
+	return out
+}
+
+// unwrap returns the submitter underneath any late binding or profiling wrappers.
+func unwrap(sub Submitter) Submitter {
+	for {
+		switch s := sub.(type) {
+		case *lateSubmitter:
+			sub = s.sub
+		case *profiledSubmitter:
+			sub = s.sub
+		default:
+			return sub
+		}
+	}
+}
+
+// latencySummary summarizes a histogram of durations in seconds.
+type latencySummary struct {
+	Count uint64  `json:"count"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+func summarizeLatency(h *flathist.Histogram) latencySummary {
+	total, _, avg, _ := h.Summary()
+	if total == 0 {
+		return latencySummary{}
+	}
+	return latencySummary{
+		Count: total,
+		Mean:  avg,
+		P50:   float64(h.Quantile(0.5)),
+		P90:   float64(h.Quantile(0.9)),
+		P99:   float64(h.Quantile(0.99)),
+		Max:   float64(h.Max()),
+	}
+}
+
+// lockTimer measures how long it takes to acquire a mutex when enabled.
+type lockTimer struct {
+	hist atomic.Pointer[flathist.Histogram]
+}
+
+// enable turns the measurements on or off, keeping the existing measurements if they were
+// already on.
+func (l *lockTimer) enable(on bool) {
+	if !on {
+		l.hist.Store(nil)
+	} else if l.hist.Load() == nil {
+		l.hist.CompareAndSwap(nil, flathist.NewHistogram())
+	}
+}
+
+func (l *lockTimer) lock(mu *sync.Mutex) {
+	h := l.hist.Load()
+	if h == nil {
+		mu.Lock()
+		return
+	}
+	start := time.Now()
+	mu.Lock()
+	h.Observe(float32(time.Since(start).Seconds()))
+}
@@ -27,10 +27,10 @@ type promLabel struct {
 
 type promSeries struct {
 	labels  []promLabel
-	count   uint64
+	count   float64
 	sum     float64
-	buckets []uint64
-	errors  uint64
+	buckets []float64
+	errors  float64
 }
 
 type PrometheusSubmitter struct {
@@ -71,6 +71,8 @@ func (p *PrometheusSubmitter) Children() []Submitter {
 
 func (p *PrometheusSubmitter) ExtraData() any { return nil }
 
+func (p *PrometheusSubmitter) Flush(ctx context.Context) error { return nil }
+
 func (p *PrometheusSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	p.live.Record(ev)
 	p.stats.received.Add(1)
@@ -123,11 +125,14 @@ func (p *PrometheusSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	if s == nil {
 		s = &promSeries{
 			labels:  labels,
-			buckets: make([]uint64, len(p.buckets)),
+			buckets: make([]float64, len(p.buckets)),
 		}
 		p.series[key] = s
 	}
 
+	// Sampled events count for the events they stand for.
+	weight := eventWeight(ev)
+
 	// Accumulate duration histogram into prometheus buckets.
 	for _, ann := range ev {
 		if ann.Key != "duration" {
@@ -139,14 +144,14 @@ func (p *PrometheusSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 		}
 
 		total, sum, _, _ := h.Summary()
-		s.count += total
-		s.sum += sum
+		tot := float64(total) * weight
+		s.count += tot
+		s.sum += sum * weight
 
 		// Use CDF to fill bucket counts. CDF returns fraction < threshold,
 		// which is close enough to le for float histograms.
-		tot := float64(total)
 		for i, bound := range p.buckets {
-			s.buckets[i] += uint64(h.CDF(float32(bound)) * tot)
+			s.buckets[i] += h.CDF(float32(bound)) * tot
 		}
 		break
 	}
@@ -163,27 +168,29 @@ func (p *PrometheusSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 
 		// success is a bool: 0.0 = false, 1.0 = true.
 		// CDF(0.5) gives fraction of values < 0.5, i.e. the false observations.
-		total := float64(h.Total())
-		s.errors += uint64(h.CDF(0.5) * total)
+		total := float64(h.Total()) * weight
+		s.errors += h.CDF(0.5) * total
 		break
 	}
 }
 
+func (p *PrometheusSubmitter) Stats() []Stat {
+	p.mu.Lock()
+	seriesActive := uint64(len(p.series))
+	p.mu.Unlock()
+	return []Stat{
+		{"received", p.stats.received.Load()},
+		{"skipped", p.stats.skipped.Load()},
+		{"series_active", seriesActive},
+	}
+}
+
 func (p *PrometheusSubmitter) Handler() http.Handler {
 	return hmux.Dir{
 		"/tree":    constJSONHandler(treeify(p)),
 		"/live":    p.live.Handler(),
 		"/metrics": http.HandlerFunc(p.metricsHandler),
-		"/stats": statsHandler(func() []stat {
-			p.mu.Lock()
-			seriesActive := uint64(len(p.series))
-			p.mu.Unlock()
-			return []stat{
-				{"received", p.stats.received.Load()},
-				{"skipped", p.stats.skipped.Load()},
-				{"series_active", seriesActive},
-			}
-		}),
+		"/stats":   statsHandler(p.Stats),
 	}
 }
 
@@ -192,10 +199,10 @@ func (p *PrometheusSubmitter) metricsHandler(w http.ResponseWriter, r *http.Requ
 	// Snapshot the series under the lock.
 	type snapshot struct {
 		labels  []promLabel
-		count   uint64
+		count   float64
 		sum     float64
-		buckets []uint64
-		errors  uint64
+		buckets []float64
+		errors  float64
 	}
 	snaps := make([]snapshot, 0, len(p.series))
 	for _, s := range p.series {
@@ -223,14 +230,14 @@ func (p *PrometheusSubmitter) metricsHandler(w http.ResponseWriter, r *http.Requ
 	for _, s := range snaps {
 		ls := formatLabels(s.labels)
 		for i, bound := range p.buckets {
-			fmt.Fprintf(w, "%s_duration_seconds_bucket{%sle=\"%g\"} %d\n",
+			fmt.Fprintf(w, "%s_duration_seconds_bucket{%sle=\"%g\"} %g\n",
 				ns, ls, bound, s.buckets[i])
 		}
-		fmt.Fprintf(w, "%s_duration_seconds_bucket{%sle=\"+Inf\"} %d\n",
+		fmt.Fprintf(w, "%s_duration_seconds_bucket{%sle=\"+Inf\"} %g\n",
 			ns, ls, s.count)
 		fmt.Fprintf(w, "%s_duration_seconds_sum{%s} %g\n",
 			ns, trimTrailingComma(ls), s.sum)
-		fmt.Fprintf(w, "%s_duration_seconds_count{%s} %d\n",
+		fmt.Fprintf(w, "%s_duration_seconds_count{%s} %g\n",
 			ns, trimTrailingComma(ls), s.count)
 	}
 
@@ -238,7 +245,7 @@ func (p *PrometheusSubmitter) metricsHandler(w http.ResponseWriter, r *http.Requ
 	fmt.Fprintf(w, "# HELP %s_events_total Total number of events.\n", ns)
 	fmt.Fprintf(w, "# TYPE %s_events_total counter\n", ns)
 	for _, s := range snaps {
-		fmt.Fprintf(w, "%s_events_total{%s} %d\n",
+		fmt.Fprintf(w, "%s_events_total{%s} %g\n",
 			ns, trimTrailingComma(formatLabels(s.labels)), s.count)
 	}
 
@@ -246,7 +253,7 @@ func (p *PrometheusSubmitter) metricsHandler(w http.ResponseWriter, r *http.Requ
 	fmt.Fprintf(w, "# HELP %s_errors_total Total number of failed events.\n", ns)
 	fmt.Fprintf(w, "# TYPE %s_errors_total counter\n", ns)
 	for _, s := range snaps {
-		fmt.Fprintf(w, "%s_errors_total{%s} %d\n",
+		fmt.Fprintf(w, "%s_errors_total{%s} %g\n",
 			ns, trimTrailingComma(formatLabels(s.labels)), s.errors)
 	}
 
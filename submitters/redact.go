@@ -0,0 +1,247 @@
+package submitters
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/zeebo/errs/v2"
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+)
+
+// redactMask replaces the matches of the rules of a RedactSubmitter without a salt.
+const redactMask = "[REDACTED]"
+
+// RedactRule finds one kind of sensitive data in annotation values.
+type RedactRule struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+// Name returns the name of the rule.
+func (r RedactRule) Name() string { return r.name }
+
+// redactBuiltins are the built-in rules, in the order they are applied.
+var redactBuiltins = []RedactRule{
+	{name: "bearer", re: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]{16,}=*`)},
+	{name: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	{name: "aws_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "email", re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{name: "credit_card", re: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), valid: luhn},
+}
+
+// RedactBuiltin returns the built-in rule with the name: bearer for bearer tokens of at least 16
+// characters, jwt for JSON web tokens, aws_key for AWS access key ids, email for email addresses,
+// or credit_card for 13 to 19 digit numbers, optionally grouped by spaces or dashes, that pass
+// the Luhn check.
+func RedactBuiltin(name string) (RedactRule, bool) {
+	for _, r := range redactBuiltins {
+		if r.name == name {
+			return r, true
+		}
+	}
+	return RedactRule{}, false
+}
+
+// RedactBuiltins returns all of the built-in rules.
+func RedactBuiltins() []RedactRule {
+	return slices.Clone(redactBuiltins)
+}
+
+// RedactPattern returns a rule with the name that matches the regular expression.
+func RedactPattern(name, pattern string) (RedactRule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return RedactRule{}, errs.Errorf("invalid redact pattern %q: %w", name, err)
+	}
+	return RedactRule{name: name, re: re}, nil
+}
+
+// luhn returns true if the digits in the string pass the Luhn checksum.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+// RedactSubmitter removes sensitive data from events before passing them to its child. It removes
+// the annotations with keys matching any of its deny globs, and replaces the matches of its rules
+// in string and bytes values with a mask, or with a keyed hash so equal values can still be
+// correlated. The submitted events are never modified, and the live buffer records the redacted
+// events so the web UI does not expose what was removed.
+type RedactSubmitter struct {
+	rules  []RedactRule
+	deny   []string
+	salt   string
+	sub    Submitter
+	live   liveBuffer
+	counts []atomic.Uint64 // redactions per rule
+
+	stats struct {
+		received atomic.Uint64
+		redacted atomic.Uint64
+		removed  atomic.Uint64
+	}
+}
+
+// NewRedactSubmitter returns a RedactSubmitter that applies the rules in order and removes the
+// annotations with keys matching any of the deny globs. Matches are replaced with [REDACTED] if
+// salt is empty, and with the hex encoded HMAC-SHA256 of the match keyed by salt, truncated to 16
+// bytes, otherwise.
+func NewRedactSubmitter(rules []RedactRule, deny []string, salt string, sub Submitter) *RedactSubmitter {
+	return &RedactSubmitter{
+		rules:  rules,
+		deny:   deny,
+		salt:   salt,
+		sub:    sub,
+		live:   newLiveBuffer(),
+		counts: make([]atomic.Uint64, len(rules)),
+	}
+}
+
+func (r *RedactSubmitter) Children() []Submitter {
+	return []Submitter{r.sub}
+}
+
+func (r *RedactSubmitter) ExtraData() any {
+	names := make([]string, len(r.rules))
+	for i, rule := range r.rules {
+		names[i] = rule.name
+	}
+	mode := "mask"
+	if r.salt != "" {
+		mode = "hash"
+	}
+	return map[string]string{
+		"rules":     strings.Join(names, ","),
+		"deny_keys": strings.Join(r.deny, ","),
+		"mode":      mode,
+	}
+}
+
+func (r *RedactSubmitter) Flush(ctx context.Context) error { return flushChildren(ctx, r) }
+
+func (r *RedactSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	r.stats.received.Add(1)
+
+	out, changed := r.redact(ev, true)
+	if changed {
+		r.stats.redacted.Add(1)
+	}
+	r.live.Record(out)
+	r.sub.Submit(ctx, out)
+}
+
+// redact returns the redacted event and whether anything was redacted, updating the stats if count
+// is set. The event is only copied if it is changed.
+func (r *RedactSubmitter) redact(ev hydrant.Event, count bool) (hydrant.Event, bool) {
+	var out hydrant.Event
+	for i, a := range ev {
+		if len(r.deny) > 0 && matchKey(r.deny, a.Key) {
+			if count {
+				r.stats.removed.Add(1)
+			}
+			if out == nil {
+				out = slices.Clone(ev[:i])
+			}
+			continue
+		}
+
+		changed := false
+		if s, ok := a.Value.String(); ok {
+			var red string
+			if red, changed = r.redactString(s, count); changed {
+				a = hydrant.String(a.Key, red)
+			}
+		} else if b, ok := a.Value.Bytes(); ok {
+			var red string
+			if red, changed = r.redactString(string(b), count); changed {
+				a = hydrant.Bytes(a.Key, []byte(red))
+			}
+		}
+
+		if out == nil && changed {
+			out = slices.Clone(ev[:i])
+		}
+		if out != nil {
+			out = append(out, a)
+		}
+	}
+	if out == nil {
+		return ev, false
+	}
+	return out, true
+}
+
+// redactString replaces the matches of the rules in the string and returns true if there were any.
+func (r *RedactSubmitter) redactString(s string, count bool) (string, bool) {
+	changed := false
+	for i := range r.rules {
+		rule := &r.rules[i]
+		s = rule.re.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.valid != nil && !rule.valid(match) {
+				return match
+			}
+			if count {
+				r.counts[i].Add(1)
+			}
+			changed = true
+			if r.salt == "" {
+				return redactMask
+			}
+			return hashString(r.salt, []byte(match))
+		})
+	}
+	return s, changed
+}
+
+func (r *RedactSubmitter) Stats() []Stat {
+	stats := []Stat{
+		{"received", r.stats.received.Load()},
+		{"redacted", r.stats.redacted.Load()},
+		{"removed_keys", r.stats.removed.Load()},
+	}
+	for i, rule := range r.rules {
+		stats = append(stats, Stat{"rule_" + rule.name, r.counts[i].Load()})
+	}
+	return stats
+}
+
+func (r *RedactSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(r)),
+		"/live":  r.live.Handler(),
+		"/sub":   r.sub.Handler(),
+		"/stats": statsHandler(r.Stats),
+	}
+}
+
+// hashString returns the hex encoded HMAC-SHA256 of data keyed by salt, truncated to 16 bytes.
+func hashString(salt string, data []byte) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
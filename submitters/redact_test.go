@@ -0,0 +1,82 @@
+package submitters
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/filter"
+)
+
+func TestRedact(t *testing.T) {
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"submitter": {
+			"kind": "redact",
+			"rules": [{"name": "ticket", "pattern": "TICKET-[0-9]+"}],
+			"deny_keys": ["password", "http.header.*"],
+			"submitter": {"kind": "null"}
+		}
+	}`), &cfg))
+
+	sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.NoError(t, err)
+	red := sub.root.(*RedactSubmitter)
+
+	ev := hydrant.Event{
+		hydrant.String("message", "mail bob@example.com about TICKET-12 with Bearer abcdefghijklmnopqrstuvwxyz"),
+		hydrant.Bytes("card", []byte("4111 1111 1111 1111")),
+		hydrant.String("order", "4111 1111 1111 1112"),
+		hydrant.String("password", "hunter2"),
+		hydrant.String("http.header.cookie", "session=1"),
+		hydrant.Int("count", 3),
+	}
+	orig := slices.Clone(ev)
+	sub.Submit(t.Context(), ev)
+	assert.DeepEqual(t, ev, orig)
+
+	got := make(map[string]any)
+	for _, a := range red.sub.(*NullSubmitter).live.buf.Get()[0] {
+		got[a.Key] = a.Value.AsAny()
+	}
+	assert.Equal(t, len(got), 4)
+	assert.Equal(t, got["message"], "mail [REDACTED] about [REDACTED] with [REDACTED]")
+	assert.Equal(t, string(got["card"].([]byte)), "[REDACTED]")
+	assert.Equal(t, got["order"], "4111 1111 1111 1112")
+	assert.Equal(t, got["count"], int64(3))
+
+	stats := make(map[string]uint64)
+	for _, s := range red.Stats() {
+		stats[s.Name] = s.Value
+	}
+	assert.Equal(t, stats["redacted"], uint64(1))
+	assert.Equal(t, stats["removed_keys"], uint64(2))
+	assert.Equal(t, stats["rule_email"], uint64(1))
+	assert.Equal(t, stats["rule_bearer"], uint64(1))
+	assert.Equal(t, stats["rule_credit_card"], uint64(1))
+	assert.Equal(t, stats["rule_ticket"], uint64(1))
+
+	// events without anything to redact are not copied.
+	clean := hydrant.Event{hydrant.String("message", "hello")}
+	out, changed := red.redact(clean, false)
+	assert.That(t, !changed)
+	assert.Equal(t, &out[0], &clean[0])
+
+	// hashing is keyed and consistent.
+	hashed := NewRedactSubmitter(RedactBuiltins(), nil, "pepper", NewNullSubmitter())
+	a, _ := hashed.redact(hydrant.Event{hydrant.String("user", "bob@example.com")}, false)
+	b, _ := hashed.redact(hydrant.Event{hydrant.String("user", "bob@example.com")}, false)
+	assert.Equal(t, a[0].Value.AsAny(), b[0].Value.AsAny())
+	assert.Equal(t, len(a[0].Value.AsAny().(string)), 32)
+
+	// hashing requires a salt.
+	_, err = Environment{}.New(config.Config{Submitter: config.RedactSubmitter{
+		Mode:      "hash",
+		Submitter: config.NullSubmitter{},
+	}})
+	assert.Error(t, err)
+}
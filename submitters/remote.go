@@ -2,10 +2,17 @@ package submitters
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io/fs"
 	"net/http"
+	"os"
 	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zeebo/swaparoo"
@@ -27,103 +34,304 @@ type runningConfiguredSubmitter struct {
 	done    chan struct{}
 }
 
+type runningShadow struct {
+	shadow *Shadow
+	cancel func()
+	done   chan struct{}
+}
+
 func (r *runningConfiguredSubmitter) stop() {
 	if r.cancel != nil {
 		r.cancel()
 		<-r.done
 		r.cancel = nil
 	}
+	if r.sub != nil {
+		r.sub.removeTaps()
+	}
 }
 
+// RemoteOptions configures optional behavior of a RemoteSubmitter.
+type RemoteOptions struct {
+	// LongPoll is passed to NewURLSource by NewRemoteSubmitterWithOptions. See NewURLSource for
+	// details. It is ignored by the other constructors.
+	LongPoll time.Duration
+
+	// CachePath, if set, is a file where the last config fetched from the source that
+	// successfully built is stored. If the first fetch fails, the cached config is used until
+	// the source is available.
+	CachePath string
+}
+
+// RemoteStatus describes the recent history of a RemoteSubmitter.
+type RemoteStatus struct {
+	Location       string       `json:"location"`
+	LastFetch      time.Time    `json:"last_fetch,omitzero"`
+	LastFetchError string       `json:"last_fetch_error,omitempty"`
+	LastError      string       `json:"last_error,omitempty"`
+	LastErrorTime  time.Time    `json:"last_error_time,omitzero"`
+	ConfigHash     string       `json:"config_hash,omitempty"`
+	Origin         string       `json:"origin,omitempty"`
+	Swaps          []RemoteSwap `json:"swaps"`
+}
+
+// RemoteSwap describes a single pipeline swap performed by a RemoteSubmitter.
+type RemoteSwap struct {
+	Time       time.Time `json:"time"`
+	ConfigHash string    `json:"config_hash"`
+	Origin     string    `json:"origin"`
+}
+
+// the origins of a config: either the config source or the cache.
+const (
+	remoteOriginSource = "source"
+	remoteOriginCache  = "cache"
+
+	maxRemoteSwaps = 16
+)
+
+// RemoteSubmitter runs the pipeline described by the config from a ConfigSource and hot-swaps it
+// whenever the config changes. If a new config fails to build, the old pipeline keeps running.
 type RemoteSubmitter struct {
-	url     string
+	src     ConfigSource
 	env     Environment
+	opts    RemoteOptions
 	trigger chan chan struct{}
 
-	mu   sync.Mutex
-	cfg  config.Config
-	swap swaparoo.Tracker
-	sub  [2]runningConfiguredSubmitter
+	refresh time.Duration // only accessed by Run
+	shadow  atomic.Pointer[runningShadow]
+
+	mu     sync.Mutex
+	closed bool
+	cfg    config.Config
+	status RemoteStatus
+	swap   swaparoo.Tracker
+	sub    [2]runningConfiguredSubmitter
 }
 
+// NewRemoteSubmitter returns a RemoteSubmitter that polls url for configs.
 func NewRemoteSubmitter(env Environment, url string) *RemoteSubmitter {
-	return &RemoteSubmitter{
+	return NewRemoteSubmitterWithOptions(env, url, nil)
+}
+
+// NewRemoteSubmitterWithOptions returns a RemoteSubmitter that polls url for configs. The
+// annotations in the environment's process store are sent along with each request.
+func NewRemoteSubmitterWithOptions(env Environment, url string, opts *RemoteOptions) *RemoteSubmitter {
+	var longPoll time.Duration
+	if opts != nil {
+		longPoll = opts.LongPoll
+	}
+	var process []hydrant.Annotation
+	if env.Process != nil {
+		process = env.Process.Annotations()
+	}
+	return NewSourceSubmitter(env, NewURLSource(url, process, longPoll), opts)
+}
+
+// NewFileConfigSubmitter returns a RemoteSubmitter that loads configs from the file at path and
+// reloads it when it changes.
+func NewFileConfigSubmitter(env Environment, path string, opts *RemoteOptions) *RemoteSubmitter {
+	return NewSourceSubmitter(env, NewFileSource(path), opts)
+}
+
+// NewSourceSubmitter returns a RemoteSubmitter that gets configs from src.
+func NewSourceSubmitter(env Environment, src ConfigSource, opts *RemoteOptions) *RemoteSubmitter {
+	r := &RemoteSubmitter{
+		src:     src,
 		env:     env,
-		url:     url,
 		trigger: make(chan chan struct{}, 1),
+		status: RemoteStatus{
+			Location: src.String(),
+			Swaps:    make([]RemoteSwap, 0),
+		},
 	}
+	if opts != nil {
+		r.opts = *opts
+	}
+	return r
 }
 
 func (r *RemoteSubmitter) Run(ctx context.Context) {
-	var interval time.Duration
+	defer r.ClearShadow()
+
 	var triggered chan struct{}
 
-	for {
-		cfg, err := r.getConfig(ctx)
-		if err == nil {
-			r.updateConfig(ctx, cfg)
-		}
+	var changed <-chan struct{}
+	if ns, ok := r.src.(notifyingSource); ok {
+		changed = ns.Changed()
+	}
 
-		interval = utils.Bound(cfg.RefreshInterval, [2]time.Duration{
-			minRemoteInterval,
-			maxRemoteInterval,
-		})
+	for {
+		again, err := r.poll(ctx)
 
 		if triggered != nil {
 			close(triggered)
 			triggered = nil
 		}
 
+		// if the source asked to be polled again, for example because the server held the
+		// request until something changed or the wait expired, do so right away.
+		if err == nil && again {
+			select {
+			case <-ctx.Done():
+				return
+			case triggered = <-r.trigger:
+			default:
+			}
+			continue
+		}
+
+		// errors retry at the minimum interval.
+		interval := minRemoteInterval
+		if err == nil {
+			interval = utils.Bound(r.refresh, [2]time.Duration{
+				minRemoteInterval,
+				maxRemoteInterval,
+			})
+		}
+
 		select {
 		case <-ctx.Done():
 			return
 		case <-time.After(utils.Jitter(interval)):
+		case <-changed:
 		case triggered = <-r.trigger:
 		}
 	}
 }
 
-func (r *RemoteSubmitter) getConfig(ctx context.Context) (cfg config.Config, err error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", r.url, nil)
+// poll fetches the config and updates to it if it has changed. It returns true if the next poll
+// should happen immediately.
+func (r *RemoteSubmitter) poll(ctx context.Context) (again bool, err error) {
+	cfg, again, err := r.src.Fetch(ctx)
+
+	r.mu.Lock()
+	r.status.LastFetch = time.Now()
+	r.status.LastFetchError = ""
 	if err != nil {
-		return config.Config{}, err
+		r.status.LastFetchError = err.Error()
 	}
+	running := r.status.Origin != ""
+	r.mu.Unlock()
 
-	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return config.Config{}, err
+		r.reportError(ctx, "fetch", err)
+
+		// if we've never had a pipeline, fall back to the cached config.
+		if !running && r.opts.CachePath != "" {
+			r.loadCache(ctx)
+		}
+
+		return false, err
+	} else if cfg == nil {
+		return again, nil
 	}
-	defer resp.Body.Close()
 
-	if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
-		return config.Config{}, err
+	r.refresh = cfg.RefreshInterval
+
+	if err := r.updateConfig(ctx, *cfg, remoteOriginSource); err != nil {
+		r.reportError(ctx, "construct", err)
 	}
 
-	return cfg, nil
+	return again, nil
 }
 
-func (r *RemoteSubmitter) updateConfig(ctx context.Context, cfg config.Config) {
-	next, err := r.env.New(cfg)
-	if err != nil {
-		// TODO: log this or something?
+// loadCache attempts to start a pipeline from the cached config.
+func (r *RemoteSubmitter) loadCache(ctx context.Context) {
+	data, err := os.ReadFile(r.opts.CachePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		r.reportError(ctx, "cache", err)
+		return
+	}
+
+	var cfg config.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		r.reportError(ctx, "cache", err)
 		return
 	}
 
+	if err := r.updateConfig(ctx, cfg, remoteOriginCache); err != nil {
+		r.reportError(ctx, "cache", err)
+	}
+}
+
+// storeCache atomically writes the config to the cache path.
+func (r *RemoteSubmitter) storeCache(data []byte) error {
+	tmp := r.opts.CachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.opts.CachePath)
+}
+
+// reportError records the error in the status and submits an event describing it.
+func (r *RemoteSubmitter) reportError(ctx context.Context, phase string, err error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	r.status.LastError = phase + ": " + err.Error()
+	r.status.LastErrorTime = now
+	r.mu.Unlock()
+
+	r.Submit(ctx, hydrant.Event{
+		hydrant.String("name", "hydrant.remote.error"),
+		hydrant.String("message", err.Error()),
+		hydrant.String("phase", phase),
+		hydrant.String("location", r.src.String()),
+		hydrant.Timestamp("timestamp", now),
+	})
+}
+
+// Status returns a description of the recent history of the submitter.
+func (r *RemoteSubmitter) Status() RemoteStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	status.Swaps = slices.Clone(status.Swaps)
+	return status
+}
+
+func (r *RemoteSubmitter) updateConfig(ctx context.Context, cfg config.Config, origin string) error {
+	data, err := cfg.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:16])
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	// once shut down, the pipeline is no longer swapped.
+	if r.closed {
+		return nil
+	}
+
 	// if the config hasn't changed, don't do anything
-	if reflect.DeepEqual(cfg, r.cfg) {
-		return
+	if reflect.DeepEqual(cfg, r.cfg) && r.status.Origin == origin {
+		return nil
 	}
-	r.cfg = cfg
 
 	// acquire a token to create the next configured submitter.
 	tok := r.swap.Acquire()
 
+	// construct the next configured submitter, carrying over any state from the current one.
+	next, err := r.env.NewFrom(cfg, r.sub[tok.Gen()%2].sub)
+	if err != nil {
+		tok.Release()
+		return err
+	}
+	r.cfg = cfg
+
+	// move the persistent taps over. the rest are dropped when the current one stops.
+	next.adoptTaps(r.sub[tok.Gen()%2].sub)
+
 	// create the next configured submitter.
 	done := make(chan struct{})
-	ctx, cancel := context.WithCancel(ctx)
+	runCtx, cancel := context.WithCancel(ctx)
 	r.sub[(tok.Gen()+1)%2] = runningConfiguredSubmitter{
 		sub:     next,
 		handler: next.Handler(),
@@ -134,7 +342,7 @@ func (r *RemoteSubmitter) updateConfig(ctx context.Context, cfg config.Config) {
 	// start it up.
 	go func() {
 		defer close(done)
-		next.Run(ctx)
+		next.Run(runCtx)
 	}()
 
 	// release the token so we can increment.
@@ -148,6 +356,108 @@ func (r *RemoteSubmitter) updateConfig(ctx context.Context, cfg config.Config) {
 
 	// clear it out to free memory
 	r.sub[tok.Gen()%2] = runningConfiguredSubmitter{}
+
+	// record the swap.
+	now := time.Now()
+	r.status.ConfigHash = hash
+	r.status.Origin = origin
+	if len(r.status.Swaps) >= maxRemoteSwaps {
+		r.status.Swaps = slices.Delete(r.status.Swaps, 0, 1)
+	}
+	r.status.Swaps = append(r.status.Swaps, RemoteSwap{
+		Time:       now,
+		ConfigHash: hash,
+		Origin:     origin,
+	})
+
+	// the new pipeline is live, so it receives the event announcing it.
+	next.Submit(ctx, hydrant.Event{
+		hydrant.String("name", "hydrant.remote.swap"),
+		hydrant.String("config_hash", hash),
+		hydrant.String("origin", origin),
+		hydrant.String("location", r.status.Location),
+		hydrant.Timestamp("timestamp", now),
+	})
+
+	// remember the config in case the source is unavailable next time we start.
+	if origin == remoteOriginSource && r.opts.CachePath != "" {
+		if err := r.storeCache(data); err != nil {
+			r.status.LastError = "cache: " + err.Error()
+			r.status.LastErrorTime = now
+		}
+	}
+
+	return nil
+}
+
+// SetShadow starts running cfg in shadow mode alongside the active pipeline, replacing any
+// existing shadow. It receives every event the active pipeline does until ClearShadow is called
+// or Run returns. See Shadow for details.
+func (r *RemoteSubmitter) SetShadow(cfg config.Config) error {
+	shadow, err := r.env.NewShadow(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rs := &runningShadow{shadow: shadow, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(rs.done)
+		shadow.Run(ctx)
+	}()
+
+	if old := r.shadow.Swap(rs); old != nil {
+		old.cancel()
+		<-old.done
+	}
+	return nil
+}
+
+// ClearShadow stops the shadow pipeline, if any.
+func (r *RemoteSubmitter) ClearShadow() {
+	if old := r.shadow.Swap(nil); old != nil {
+		old.cancel()
+		<-old.done
+	}
+}
+
+// Shadow returns the running shadow pipeline or nil if there is none.
+func (r *RemoteSubmitter) Shadow() *Shadow {
+	if rs := r.shadow.Load(); rs != nil {
+		return rs.shadow
+	}
+	return nil
+}
+
+// Flush flushes the active pipeline. See Submitter.Flush for details.
+func (r *RemoteSubmitter) Flush(ctx context.Context) error {
+	tok := r.swap.Acquire()
+	defer tok.Release()
+
+	if sub := r.sub[tok.Gen()%2].sub; sub != nil {
+		return sub.Flush(ctx)
+	}
+	return nil
+}
+
+// Shutdown stops the shadow pipeline, stops swapping configs and shuts down the active pipeline.
+// See ConfiguredSubmitter.Shutdown for details.
+func (r *RemoteSubmitter) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	r.ClearShadow()
+
+	// no swaps happen once closed is set, so the pipeline can be shut down without holding the
+	// lock, which would block the status and config updates for as long as the flush takes.
+	r.mu.Lock()
+	r.closed = true
+	tok := r.swap.Acquire()
+	sub := r.sub[tok.Gen()%2].sub
+	tok.Release()
+	r.mu.Unlock()
+
+	if sub == nil {
+		return ShutdownReport{}, nil
+	}
+	return sub.Shutdown(ctx)
 }
 
 func (r *RemoteSubmitter) Trigger() {
@@ -166,9 +476,22 @@ func (r *RemoteSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	if sub := r.sub[tok.Gen()%2].sub; sub != nil {
 		sub.Submit(ctx, ev)
 	}
+	if rs := r.shadow.Load(); rs != nil {
+		rs.shadow.Submit(ctx, ev)
+	}
 }
 
 func (r *RemoteSubmitter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/remote" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.Status())
+		return
+	}
+	if req.URL.Path == "/shadow" {
+		r.serveShadow(w, req)
+		return
+	}
+
 	tok := r.swap.Acquire()
 	defer tok.Release()
 
@@ -178,3 +501,41 @@ func (r *RemoteSubmitter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, "no submitter configured", http.StatusServiceUnavailable)
 	}
 }
+
+// serveShadow reports on the shadow pipeline with GET, starts one with the config in the body of
+// a PUT and stops it with DELETE.
+func (r *RemoteSubmitter) serveShadow(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		if shadow := r.Shadow(); shadow != nil {
+			shadow.Handler().ServeHTTP(w, req)
+		} else {
+			http.Error(w, "no shadow configured", http.StatusNotFound)
+		}
+
+	case http.MethodPut:
+		if !requireAdmin(w, req) {
+			return
+		}
+		var cfg config.Config
+		if err := json.NewDecoder(req.Body).Decode(&cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := r.SetShadow(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if !requireAdmin(w, req) {
+			return
+		}
+		r.ClearShadow()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
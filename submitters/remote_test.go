@@ -2,17 +2,25 @@ package submitters
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/json/jsontext"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/zeebo/assert"
 
 	"github.com/histdb/histdb/flathist"
 
 	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/filter"
 )
 
 func TestRemote(t *testing.T) {
@@ -46,3 +54,307 @@ func TestRemote(t *testing.T) {
 	(*jsontext.Value)(&body).Indent(jsontext.Multiline(false))
 	t.Logf("response: %s", body)
 }
+
+func TestRemoteConditionalLongPoll(t *testing.T) {
+	var mu sync.Mutex
+	var fetches, notModified int
+	changed := make(chan struct{})
+	etag := `"v1"`
+	cfg := `{"submitter": {"kind": "null"}}`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		cur, ch := etag, changed
+		mu.Unlock()
+
+		if r.Header.Get("If-None-Match") == cur {
+			assert.Equal(t, r.Header.Get("Prefer"), "wait=60")
+			w.Header().Set("Preference-Applied", "wait=60")
+			select {
+			case <-ch:
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(cfg))
+	}))
+	defer srv.Close()
+
+	rem := NewRemoteSubmitterWithOptions(Environment{}, srv.URL, &RemoteOptions{LongPoll: time.Minute})
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go rem.Run(ctx)
+
+	// wait for the initial config to be fetched, and then change it out from under the long poll.
+	for {
+		mu.Lock()
+		n := fetches
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	etag, cfg = `"v2"`, `{"submitter": {"kind": "hydrator"}}`
+	close(changed)
+	changed = make(chan struct{})
+	mu.Unlock()
+
+	for {
+		rem.mu.Lock()
+		_, ok := rem.cfg.Submitter.(config.HydratorSubmitter)
+		rem.mu.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, fetches, 2)
+	assert.Equal(t, notModified, 0)
+}
+
+func TestURLSourceEarlyLongPoll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Header.Get("Prefer"), "wait=1")
+		w.Header().Set("Preference-Applied", r.Header.Get("Prefer"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"submitter": {"kind": "null"}}`))
+	}))
+	defer srv.Close()
+
+	src := NewURLSource(srv.URL, nil, 100*time.Millisecond)
+
+	cfg, again, err := src.Fetch(t.Context())
+	assert.NoError(t, err)
+	assert.NotNil(t, cfg)
+	assert.True(t, again)
+
+	// the server answered right away without a new config, so it isn't polled again right away.
+	cfg, again, err = src.Fetch(t.Context())
+	assert.NoError(t, err)
+	assert.Nil(t, cfg)
+	assert.False(t, again)
+}
+
+func TestRemoteCache(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "config.json")
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"submitter": {"kind": "hydrator"}}`))
+	}))
+	defer good.Close()
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	// fetch a config from the good server so that it is cached.
+	{
+		ctx, cancel := context.WithCancel(t.Context())
+		rem := NewRemoteSubmitterWithOptions(Environment{}, good.URL, &RemoteOptions{CachePath: cache})
+		go rem.Run(ctx)
+		rem.Trigger()
+		cancel()
+
+		status := rem.Status()
+		assert.Equal(t, status.Origin, "source")
+		assert.Equal(t, len(status.Swaps), 1)
+		assert.Equal(t, status.LastError, "")
+	}
+
+	// a submitter that can't reach the server starts from the cache.
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	rem := NewRemoteSubmitterWithOptions(Environment{}, bad.URL, &RemoteOptions{CachePath: cache})
+	go rem.Run(ctx)
+	rem.Trigger()
+
+	status := rem.Status()
+	assert.Equal(t, status.Origin, "cache")
+	assert.That(t, status.LastFetchError != "")
+	assert.That(t, strings.HasPrefix(status.LastError, "fetch: "))
+
+	rec := httptest.NewRecorder()
+	rem.ServeHTTP(rec, httptest.NewRequest("GET", "/tree", nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	rec = httptest.NewRecorder()
+	rem.ServeHTTP(rec, httptest.NewRequest("GET", "/remote", nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	t.Logf("status: %s", rec.Body.Bytes())
+}
+
+func TestFileConfigSubmitter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(data string, mtime time.Time) {
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	kind := func(rem *RemoteSubmitter) config.Submitter {
+		rem.mu.Lock()
+		defer rem.mu.Unlock()
+		return rem.cfg.Submitter
+	}
+
+	now := time.Now()
+	write(`{"submitter": {"kind": "null"}}`, now)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	rem := NewFileConfigSubmitter(Environment{}, path, nil)
+	go rem.Run(ctx)
+	rem.Trigger()
+	assert.Equal(t, kind(rem), config.NullSubmitter{})
+
+	// a config that fails to build keeps the old pipeline running.
+	write(`{"submitter": "missing"}`, now.Add(time.Second))
+	rem.Trigger()
+	assert.Equal(t, kind(rem), config.NullSubmitter{})
+	assert.That(t, strings.HasPrefix(rem.Status().LastError, "construct: "))
+
+	write(`{"submitter": {"kind": "hydrator"}}`, now.Add(2*time.Second))
+	rem.Trigger()
+	assert.Equal(t, kind(rem), config.HydratorSubmitter{})
+	assert.Equal(t, len(rem.Status().Swaps), 2)
+}
+
+func TestMemorySource(t *testing.T) {
+	src := NewMemorySource()
+	rem := NewSourceSubmitter(Environment{}, src, nil)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go rem.Run(ctx)
+
+	src.Set(config.Config{Submitter: config.HydratorSubmitter{}})
+	for len(rem.Status().Swaps) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, rem.Status().Location, "memory")
+}
+
+func TestShadow(t *testing.T) {
+	src := NewMemorySource()
+	rem := NewSourceSubmitter(Environment{Filter: filter.NewBuiltinEnvionment()}, src, nil)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go rem.Run(ctx)
+
+	src.Set(config.Config{Submitter: config.HydratorSubmitter{}})
+	for len(rem.Status().Swaps) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	candidate := `{
+		"submitter": {
+			"kind": "filter",
+			"filter": "eq(key(name), keep)",
+			"submitter": {"kind": "http", "endpoint": "http://example.invalid"}
+		}
+	}`
+
+	// changes are refused without access control.
+	rec := httptest.NewRecorder()
+	rem.ServeHTTP(rec, httptest.NewRequest("PUT", "/shadow", strings.NewReader(candidate)))
+	assert.Equal(t, rec.Code, http.StatusForbidden)
+	assert.Nil(t, rem.Shadow())
+
+	admin := Access{Anonymous: RoleAdmin}.Handler(rem)
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest("PUT", "/shadow", strings.NewReader(candidate)))
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+
+	rem.Submit(ctx, hydrant.Event{hydrant.String("name", "keep")})
+	rem.Submit(ctx, hydrant.Event{hydrant.String("name", "drop")})
+
+	rep := rem.Shadow().Report()
+	assert.Equal(t, rep.Received, uint64(2))
+	assert.Equal(t, rep.ExportedEvents, uint64(1))
+	assert.That(t, rep.ExportedBytes > 0)
+
+	rec = httptest.NewRecorder()
+	rem.ServeHTTP(rec, httptest.NewRequest("GET", "/shadow", nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.That(t, strings.Contains(rec.Body.String(), `"replaces": "HTTPSubmitter"`))
+
+	admin.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/shadow", nil))
+	assert.Nil(t, rem.Shadow())
+}
+
+func TestTaps(t *testing.T) {
+	src := NewMemorySource()
+	rem := NewSourceSubmitter(Environment{Filter: filter.NewBuiltinEnvionment()}, src, nil)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go rem.Run(ctx)
+
+	swap := func(cfg string) {
+		var c config.Config
+		assert.NoError(t, json.Unmarshal([]byte(cfg), &c))
+		n := len(rem.Status().Swaps)
+		src.Set(c)
+		for len(rem.Status().Swaps) == n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	swap(`{"submitter": {"kind": "filter", "filter": "has(name)", "submitter": {"kind": "null"}}}`)
+
+	admin := Access{Anonymous: RoleAdmin}.Handler(rem)
+	addTap := func(body string) (info TapInfo) {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest("POST", "/taps", strings.NewReader(body)))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		return info
+	}
+	persist := addTap(`{"path": "/sub/sub", "kind": "stream", "filter": "eq(key(name), x)", "persist": true, "ttl": "1m"}`)
+	dropped := addTap(`{"path": "/sub"}`)
+
+	// the tap sees the events that reach the tapped submitter.
+	current := func() *ConfiguredSubmitter {
+		tok := rem.swap.Acquire()
+		defer tok.Release()
+		return rem.sub[tok.Gen()%2].sub
+	}
+	for current().tap(persist.ID).sub.stats.passed.Load() == 0 {
+		rem.Submit(ctx, hydrant.Event{hydrant.String("name", "x")})
+		rem.Submit(ctx, hydrant.Event{hydrant.String("name", "y")})
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	rem.ServeHTTP(rec, httptest.NewRequest("GET", "/tree", nil))
+	assert.That(t, strings.Contains(rec.Body.String(), persist.ID))
+
+	// persistent taps survive swaps when the path still exists.
+	swap(`{"submitter": {"kind": "filter", "filter": "has(other)", "submitter": {"kind": "null"}}}`)
+	assert.Equal(t, len(current().Taps()), 1)
+	assert.Equal(t, current().Taps()[0].ID, persist.ID)
+	assert.Nil(t, current().tap(dropped.ID))
+
+	// expiring removes the tap.
+	current().tap(persist.ID).expire()
+	assert.Equal(t, len(current().Taps()), 0)
+}
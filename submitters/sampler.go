@@ -0,0 +1,327 @@
+package submitters
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/value"
+)
+
+// Sampler modes.
+const (
+	SampleProbability = "probability"
+	SampleRate        = "rate"
+	SampleKeyRate     = "key_rate"
+)
+
+const (
+	// sampleRateKey is the annotation stamped on passed events with the number of events each
+	// one stands for.
+	sampleRateKey = "sample_rate"
+
+	// weightKey is an annotation for events that stand for some number of events without being
+	// sampled by a SamplerSubmitter.
+	weightKey = "_weight"
+
+	// maxSamplerKeys bounds the number of per-key buckets. Idle buckets are dropped when it is
+	// reached, and all of them if none are idle.
+	maxSamplerKeys = 10000
+	samplerKeyIdle = time.Minute
+)
+
+// SamplerSubmitter passes a sample of the events to its child and stamps a sample_rate annotation
+// on them with the number of events each one stands for. If the event already has a sample_rate
+// from an earlier sampler, the rates are multiplied.
+type SamplerSubmitter struct {
+	mode  string
+	prob  float64
+	rate  float64
+	burst float64
+	key   string
+	sub   Submitter
+	live  liveBuffer
+
+	stats struct {
+		received atomic.Uint64
+		passed   atomic.Uint64
+		dropped  atomic.Uint64
+	}
+
+	wait    lockTimer
+	mu      sync.Mutex
+	buckets map[string]*sampleBucket
+}
+
+// NewProbabilitySampler passes events with the probability p. Events with a trace_id are sampled
+// by trace, so all or none of the spans in a trace are passed.
+func NewProbabilitySampler(p float64, sub Submitter) *SamplerSubmitter {
+	return &SamplerSubmitter{
+		mode: SampleProbability,
+		prob: min(max(p, 0), 1),
+		sub:  sub,
+		live: newLiveBuffer(),
+	}
+}
+
+// NewRateSampler passes at most rate events per second with bursts of up to burst events. A burst
+// less than one defaults to one second's worth of events.
+func NewRateSampler(rate float64, burst int, sub Submitter) *SamplerSubmitter {
+	return NewKeyRateSampler("", rate, burst, sub)
+}
+
+// NewKeyRateSampler is like NewRateSampler, but the limit applies to the events with each value
+// of the annotation key separately. An empty key is the same as NewRateSampler.
+func NewKeyRateSampler(key string, rate float64, burst int, sub Submitter) *SamplerSubmitter {
+	mode := SampleKeyRate
+	if key == "" {
+		mode = SampleRate
+	}
+	b := float64(burst)
+	if b < 1 {
+		b = max(rate, 1)
+	}
+	return &SamplerSubmitter{
+		mode:    mode,
+		rate:    rate,
+		burst:   b,
+		key:     key,
+		sub:     sub,
+		live:    newLiveBuffer(),
+		buckets: make(map[string]*sampleBucket),
+	}
+}
+
+func (s *SamplerSubmitter) Children() []Submitter {
+	return []Submitter{s.sub}
+}
+
+func (s *SamplerSubmitter) ExtraData() any {
+	switch s.mode {
+	case SampleProbability:
+		return map[string]string{"mode": s.mode, "probability": strconv.FormatFloat(s.prob, 'g', -1, 64)}
+	default:
+		extra := map[string]string{
+			"mode":  s.mode,
+			"rate":  strconv.FormatFloat(s.rate, 'g', -1, 64),
+			"burst": strconv.FormatFloat(s.burst, 'g', -1, 64),
+		}
+		if s.key != "" {
+			extra["key"] = s.key
+		}
+		return extra
+	}
+}
+
+func (s *SamplerSubmitter) lockWait() *lockTimer { return &s.wait }
+
+func (s *SamplerSubmitter) Flush(ctx context.Context) error { return flushChildren(ctx, s) }
+
+func (s *SamplerSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	s.live.Record(ev)
+	s.stats.received.Add(1)
+
+	ok, rate := s.sample(ev, time.Now())
+	if !ok {
+		s.stats.dropped.Add(1)
+		return
+	}
+	s.stats.passed.Add(1)
+	s.sub.Submit(ctx, withSampleRate(ev, rate))
+}
+
+// sample decides if the event is passed and how many events it stands for if so.
+func (s *SamplerSubmitter) sample(ev hydrant.Event, now time.Time) (bool, float64) {
+	if s.mode == SampleProbability {
+		if s.prob == 0 {
+			return false, 0
+		}
+		return sampleFraction(ev) < s.prob, 1 / s.prob
+	}
+
+	key := ""
+	if s.key != "" {
+		key = sampleKey(ev, s.key)
+	}
+
+	s.wait.lock(&s.mu)
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= maxSamplerKeys {
+			s.evictBuckets(now)
+		}
+		b = &sampleBucket{limit: tokenBucket{rate: s.rate, burst: s.burst}}
+		s.buckets[key] = b
+	}
+	return b.allow(now)
+}
+
+// evictBuckets drops the idle buckets, or all of them if none are idle.
+func (s *SamplerSubmitter) evictBuckets(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.limit.last) > samplerKeyIdle {
+			delete(s.buckets, key)
+		}
+	}
+	if len(s.buckets) >= maxSamplerKeys {
+		clear(s.buckets)
+	}
+}
+
+func (s *SamplerSubmitter) Stats() []Stat {
+	stats := []Stat{
+		{"received", s.stats.received.Load()},
+		{"passed", s.stats.passed.Load()},
+		{"dropped", s.stats.dropped.Load()},
+	}
+	if s.mode == SampleKeyRate {
+		s.mu.Lock()
+		stats = append(stats, Stat{"keys", uint64(len(s.buckets))})
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+func (s *SamplerSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(s)),
+		"/live":  s.live.Handler(),
+		"/sub":   s.sub.Handler(),
+		"/stats": statsHandler(s.Stats),
+	}
+}
+
+// sampleFraction returns a number in [0, 1) that is derived from the trace_id of the event if it
+// has one and random otherwise.
+func sampleFraction(ev hydrant.Event) float64 {
+	if id, ok := eventTraceID(ev); ok {
+		return traceFraction(id)
+	}
+	return rand.Float64()
+}
+
+// traceFraction maps the trace id to a number in [0, 1).
+func traceFraction(id [16]byte) float64 {
+	return float64(binary.BigEndian.Uint64(id[8:])>>11) / (1 << 53)
+}
+
+// sampleKey returns the value of the annotation key in the event as a string.
+func sampleKey(ev hydrant.Event, key string) string {
+	for _, a := range ev {
+		if a.Key == key {
+			return valueString(a.Value)
+		}
+	}
+	return ""
+}
+
+// withSampleRate returns a copy of the event with the sample rate annotation multiplied by rate.
+func withSampleRate(ev hydrant.Event, rate float64) hydrant.Event {
+	out := make(hydrant.Event, 0, len(ev)+1)
+	for _, a := range ev {
+		if a.Key == sampleRateKey {
+			if prev, ok := a.Value.Float(); ok {
+				rate *= prev
+			}
+			continue
+		}
+		out = append(out, a)
+	}
+	return append(out, hydrant.Float(sampleRateKey, rate))
+}
+
+// isWeightKey returns true for the annotations that eventWeight reads.
+func isWeightKey(key string) bool {
+	return key == sampleRateKey || key == weightKey
+}
+
+// eventWeight returns the number of events the event stands for, the product of its sample_rate
+// and _weight annotations. It is one for events that have neither.
+func eventWeight(ev hydrant.Event) float64 {
+	weight := 1.
+	for _, a := range ev {
+		if !isWeightKey(a.Key) {
+			continue
+		}
+		var w float64
+		switch a.Value.Kind() {
+		case value.KindFloat:
+			w, _ = a.Value.Float()
+		case value.KindInt:
+			x, _ := a.Value.Int()
+			w = float64(x)
+		case value.KindUint:
+			x, _ := a.Value.Uint()
+			w = float64(x)
+		default:
+			continue
+		}
+		if w > 0 {
+			weight *= w
+		}
+	}
+	return weight
+}
+
+// sampleBucket rate limits the events for one key. The events passed in a window of a second are
+// stamped with the ratio of received to passed events in the previous window.
+type sampleBucket struct {
+	limit  tokenBucket
+	window time.Time
+	seen   uint64
+	passed uint64
+	weight float64
+}
+
+func (b *sampleBucket) allow(now time.Time) (bool, float64) {
+	if elapsed := now.Sub(b.window); elapsed >= time.Second {
+		b.weight = 1
+		if elapsed < 2*time.Second && b.passed > 0 {
+			b.weight = float64(b.seen) / float64(b.passed)
+		}
+		b.window, b.seen, b.passed = now, 0, 0
+	}
+
+	b.seen++
+	if !b.limit.allow(now) {
+		return false, 0
+	}
+	b.passed++
+	return true, b.weight
+}
+
+// tokenBucket allows rate events per second with bursts of up to burst events. A zero rate allows
+// everything.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (t *tokenBucket) allow(now time.Time) bool {
+	if t.rate == 0 {
+		return true
+	}
+	if t.last.IsZero() {
+		t.tokens = t.burst
+	} else {
+		t.tokens = min(t.tokens+now.Sub(t.last).Seconds()*t.rate, t.burst)
+	}
+	t.last = now
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
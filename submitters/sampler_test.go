@@ -0,0 +1,61 @@
+package submitters
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/filter"
+)
+
+func TestSampler(t *testing.T) {
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"submitter": {
+			"kind": "sampler",
+			"mode": "key_rate",
+			"key": "name",
+			"rate": 2,
+			"submitter": {"kind": "null"}
+		}
+	}`), &cfg))
+
+	sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.NoError(t, err)
+
+	for range 10 {
+		sub.Submit(t.Context(), hydrant.Event{hydrant.String("name", "a")})
+		sub.Submit(t.Context(), hydrant.Event{hydrant.String("name", "b")})
+	}
+	sam := sub.root.(*SamplerSubmitter)
+	assert.Equal(t, sam.stats.passed.Load(), uint64(4))
+	assert.Equal(t, sam.stats.dropped.Load(), uint64(16))
+
+	// the next window stamps the ratio of received to passed events from the last one.
+	now := time.Now()
+	rate := NewRateSampler(2, 0, NewNullSubmitter())
+	for range 10 {
+		rate.sample(nil, now)
+	}
+	ok, weight := rate.sample(nil, now.Add(time.Second))
+	assert.That(t, ok)
+	assert.Equal(t, weight, 5.)
+
+	// probability sampling keeps or drops whole traces.
+	prob := NewProbabilitySampler(0.5, NewNullSubmitter())
+	for i := range 20 {
+		trace := hydrant.TraceId("trace_id", [16]byte{15: byte(i)})
+		first, _ := prob.sample(hydrant.Event{trace}, now)
+		second, weight := prob.sample(hydrant.Event{trace, hydrant.Int("i", 1)}, now)
+		assert.Equal(t, first, second)
+		assert.Equal(t, weight, 2.)
+	}
+
+	ev := withSampleRate(hydrant.Event{hydrant.Float("sample_rate", 2)}, 5)
+	assert.Equal(t, len(ev), 1)
+	assert.Equal(t, ev[0].Value.AsAny(), 10.)
+}
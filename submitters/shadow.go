@@ -0,0 +1,182 @@
+package submitters
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+)
+
+// Shadow runs a candidate pipeline alongside the active one. It is constructed from a config like
+// a ConfiguredSubmitter, but every output (exporters and in-memory stores) is replaced with a
+// ShadowSinkSubmitter that only counts what it would have received. Filters and groupers run for
+// real, so their stats show what the candidate would pass, drop and group.
+type Shadow struct {
+	cfg     config.Config
+	sub     *ConfiguredSubmitter
+	started time.Time
+
+	stats struct {
+		received atomic.Uint64
+	}
+}
+
+// NewShadow constructs a Shadow for the candidate config.
+func (env Environment) NewShadow(cfg config.Config) (*Shadow, error) {
+	sub, err := env.newConfigured(cfg, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Shadow{
+		cfg:     cfg,
+		sub:     sub,
+		started: time.Now(),
+	}, nil
+}
+
+func (s *Shadow) Run(ctx context.Context) { s.sub.Run(ctx) }
+
+func (s *Shadow) Submit(ctx context.Context, ev hydrant.Event) {
+	s.stats.received.Add(1)
+	s.sub.Submit(ctx, ev)
+}
+
+// ShadowReport summarizes what a candidate pipeline has done with the events it received.
+type ShadowReport struct {
+	Config   config.Config `json:"config"`
+	Started  time.Time     `json:"started"`
+	Received uint64        `json:"received"`
+
+	// ExportedEvents and ExportedBytes total what the replaced outputs would have received. The
+	// bytes are estimated with the binary event encoding.
+	ExportedEvents uint64 `json:"exported_events"`
+	ExportedBytes  uint64 `json:"exported_bytes"`
+
+	// Tree is the pipeline with the stats of every submitter in it.
+	Tree any `json:"tree"`
+}
+
+// Report returns the current report for the shadow pipeline.
+func (s *Shadow) Report() ShadowReport {
+	rep := ShadowReport{
+		Config:   s.sub.Config(),
+		Started:  s.started,
+		Received: s.stats.received.Load(),
+		Tree:     statsTree(s.sub.root),
+	}
+
+	seen := make(map[*ShadowSinkSubmitter]bool)
+	var walk func(sub Submitter)
+	walk = func(sub Submitter) {
+		if sink, ok := unwrap(sub).(*ShadowSinkSubmitter); ok && !seen[sink] {
+			seen[sink] = true
+			rep.ExportedEvents += sink.stats.received.Load()
+			rep.ExportedBytes += sink.stats.bytes.Load()
+		}
+		for _, child := range sub.Children() {
+			walk(child)
+		}
+	}
+	walk(s.sub.root)
+
+	return rep
+}
+
+// Handler serves the report as JSON.
+func (s *Shadow) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		rep := s.Report()
+		rep.Config = rep.Config.Redacted()
+		enc.Encode(rep)
+	})
+}
+
+// statsTree is like treeify but includes the current stats of every submitter.
+func statsTree(sub Submitter) any {
+	sub = unwrap(sub)
+	var children []any
+	for _, child := range sub.Children() {
+		children = append(children, statsTree(child))
+	}
+	return map[string]any{
+		"kind":  reflect.TypeOf(sub).Elem().Name(),
+		"sub":   children,
+		"extra": sub.ExtraData(),
+		"stats": sub.Stats(),
+	}
+}
+
+//
+// shadow sink
+//
+
+var shadowSinkBufPool = sync.Pool{New: func() any { return new([]byte) }}
+
+// ShadowSinkSubmitter stands in for an output in a shadow pipeline. It counts the events and the
+// encoded bytes it receives.
+type ShadowSinkSubmitter struct {
+	replaces string
+	extra    any
+	live     liveBuffer
+
+	stats struct {
+		received atomic.Uint64
+		bytes    atomic.Uint64
+	}
+}
+
+// NewShadowSinkSubmitter returns a sink standing in for a submitter of the replaces kind. The
+// extra data of the replaced submitter is reported along with it.
+func NewShadowSinkSubmitter(replaces string, extra any) *ShadowSinkSubmitter {
+	return &ShadowSinkSubmitter{
+		replaces: replaces,
+		extra:    extra,
+		live:     newLiveBuffer(),
+	}
+}
+
+func (s *ShadowSinkSubmitter) Children() []Submitter {
+	return []Submitter{}
+}
+
+func (s *ShadowSinkSubmitter) ExtraData() any {
+	return map[string]any{"replaces": s.replaces, "extra": s.extra}
+}
+
+func (s *ShadowSinkSubmitter) Flush(ctx context.Context) error { return nil }
+
+func (s *ShadowSinkSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	s.live.Record(ev)
+	s.stats.received.Add(1)
+
+	buf := shadowSinkBufPool.Get().(*[]byte)
+	*buf = ev.AppendTo((*buf)[:0])
+	s.stats.bytes.Add(uint64(len(*buf)))
+	shadowSinkBufPool.Put(buf)
+}
+
+func (s *ShadowSinkSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", s.stats.received.Load()},
+		{"bytes", s.stats.bytes.Load()},
+	}
+}
+
+func (s *ShadowSinkSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(s)),
+		"/live":  s.live.Handler(),
+		"/stats": statsHandler(s.Stats),
+	}
+}
@@ -0,0 +1,223 @@
+package submitters
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs/v2"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+)
+
+// ConfigSource provides configs to a RemoteSubmitter. Fetch is only called by one goroutine at a
+// time.
+type ConfigSource interface {
+	// Fetch returns the latest config. It returns a nil config and no error if the config has
+	// not changed since the last call. If again is true, Fetch is called again right away
+	// instead of after the config's refresh interval.
+	Fetch(ctx context.Context) (cfg *config.Config, again bool, err error)
+
+	// String describes where configs come from.
+	String() string
+}
+
+// notifyingSource is implemented by sources that know when their config changes. A RemoteSubmitter
+// calls Fetch whenever the channel returned by Changed is ready.
+type notifyingSource interface {
+	Changed() <-chan struct{}
+}
+
+//
+// url source
+//
+
+// URLSource fetches configs from an HTTP endpoint. It sends the ETag of the last config as
+// If-None-Match so that unchanged configs only cost a 304, and optionally asks the server to
+// long poll.
+type URLSource struct {
+	url      string
+	process  []hydrant.Annotation
+	longPoll time.Duration
+	etag     string
+}
+
+// NewURLSource returns a source fetching configs from url. The process annotations are sent as
+// query parameters so that the server can pick a config for this process. If longPoll is positive, each
+// request asks the server to hold it for up to that long until a new config is available with a
+// "Prefer: wait=N" header. Servers that support it respond with a Preference-Applied header and
+// are polled again right away, unless they answer in less than half of the wait without a new
+// config. Servers that don't are polled at the config's refresh interval. The wait is rounded up
+// to whole seconds.
+func NewURLSource(url string, process []hydrant.Annotation, longPoll time.Duration) *URLSource {
+	if longPoll > 0 {
+		longPoll = (longPoll + time.Second - 1).Truncate(time.Second)
+	}
+	return &URLSource{
+		url:      url,
+		process:  process,
+		longPoll: longPoll,
+	}
+}
+
+func (u *URLSource) String() string { return u.url }
+
+func (u *URLSource) Fetch(ctx context.Context) (cfg *config.Config, again bool, err error) {
+	if u.longPoll > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, u.longPoll+minRemoteInterval)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(u.process) > 0 {
+		query := req.URL.Query()
+		for _, a := range u.process {
+			query.Set(a.Key, annotationString(a))
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+	if u.etag != "" {
+		req.Header.Set("If-None-Match", u.etag)
+	}
+	if u.longPoll > 0 {
+		req.Header.Set("Prefer", "wait="+strconv.Itoa(int(u.longPoll/time.Second)))
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	held := u.longPoll > 0 && strings.HasPrefix(resp.Header.Get("Preference-Applied"), "wait")
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		// a server that claims to hold the request but answers early would be polled in a tight
+		// loop, so fall back to the refresh interval.
+		return nil, held && time.Since(start) >= u.longPoll/2, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, errs.Errorf("unexpected status code fetching config: %d", resp.StatusCode)
+	}
+
+	cfg = new(config.Config)
+	if err := json.NewDecoder(resp.Body).Decode(cfg); err != nil {
+		return nil, false, err
+	}
+	u.etag = resp.Header.Get("ETag")
+
+	// when long polling, ask again right away after a new config so the next request can be held
+	// with the new etag.
+	return cfg, held || (u.longPoll > 0 && u.etag != ""), nil
+}
+
+// annotationString returns the value of the annotation as a string.
+func annotationString(a hydrant.Annotation) string {
+	if x, ok := a.Value.String(); ok {
+		return x
+	}
+	return a.String()[len(a.Key)+1:] // strip "key=" prefix from String()
+}
+
+//
+// file source
+//
+
+// FileSource loads configs from a file on disk, like a mounted ConfigMap. Changes are detected
+// by polling the modification time and size of the file and comparing a hash of its contents.
+type FileSource struct {
+	path    string
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+// NewFileSource returns a source that loads configs from the file at path.
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+func (f *FileSource) String() string { return "file:" + f.path }
+
+func (f *FileSource) Fetch(ctx context.Context) (cfg *config.Config, again bool, err error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return nil, false, err
+	}
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return nil, false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the file may have been touched or rewritten with the same contents.
+	sum := sha256.Sum256(data)
+	f.modTime, f.size = fi.ModTime(), fi.Size()
+	if bytes.Equal(sum[:], f.sum[:]) {
+		return nil, false, nil
+	}
+	f.sum = sum
+
+	cfg = new(config.Config)
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, false, errs.Errorf("parsing %q: %w", f.path, err)
+	}
+	return cfg, false, nil
+}
+
+//
+// memory source
+//
+
+// MemorySource provides configs set in process. A RemoteSubmitter using it picks up configs as
+// soon as they are Set.
+type MemorySource struct {
+	mu      sync.Mutex
+	cfg     *config.Config
+	changed chan struct{}
+}
+
+// NewMemorySource returns a source with no config. Configs are provided with Set.
+func NewMemorySource() *MemorySource {
+	return &MemorySource{changed: make(chan struct{}, 1)}
+}
+
+func (m *MemorySource) String() string { return "memory" }
+
+// Set replaces the config provided by the source.
+func (m *MemorySource) Set(cfg config.Config) {
+	m.mu.Lock()
+	m.cfg = &cfg
+	m.mu.Unlock()
+
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (m *MemorySource) Changed() <-chan struct{} { return m.changed }
+
+func (m *MemorySource) Fetch(ctx context.Context) (cfg *config.Config, again bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cfg, m.cfg = m.cfg, nil
+	return cfg, false, nil
+}
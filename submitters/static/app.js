@@ -167,6 +167,9 @@ function createTreeNode(node, path, isRoot = false) {
 
     headerDiv.appendChild(expandSpan);
     headerDiv.appendChild(kindSpan);
+    if (node.profile) {
+        headerDiv.appendChild(createProfileSpan(node.profile));
+    }
     nodeDiv.appendChild(headerDiv);
 
     // Children
@@ -1574,6 +1577,36 @@ function packSpansIntoLanes(spans, traceStart, traceDuration) {
     return lanes;
 }
 
+// Thresholds in seconds for the p99 submit time of a profiled submitter.
+const profileSlow = 1e-3;
+const profileWarn = 1e-4;
+
+// Render the submit (and lock wait) times of a profiled submitter, colored by how slow it is.
+function createProfileSpan(profile) {
+    const span = document.createElement('span');
+    span.className = 'tree-node-profile';
+
+    const submit = profile.submit || {};
+    if (submit.p99 >= profileSlow) {
+        span.classList.add('tree-node-profile-slow');
+    } else if (submit.p99 >= profileWarn) {
+        span.classList.add('tree-node-profile-warn');
+    }
+
+    let text = `p99 ${formatDuration((submit.p99 || 0) * 1e9)}`;
+    let title = `submit: ${submit.count || 0} calls, mean ${formatDuration((submit.mean || 0) * 1e9)}, ` +
+        `p50 ${formatDuration((submit.p50 || 0) * 1e9)}, p90 ${formatDuration((submit.p90 || 0) * 1e9)}, ` +
+        `max ${formatDuration((submit.max || 0) * 1e9)}`;
+    if (profile.lock_wait) {
+        text += `, lock p99 ${formatDuration(profile.lock_wait.p99 * 1e9)}`;
+        title += `\nlock wait: mean ${formatDuration(profile.lock_wait.mean * 1e9)}, ` +
+            `max ${formatDuration(profile.lock_wait.max * 1e9)}`;
+    }
+    span.textContent = text;
+    span.title = title;
+    return span;
+}
+
 // Format nanoseconds as a human-readable duration string.
 function formatDuration(ns) {
     if (ns >= 60e9) return (ns / 60e9).toFixed(1) + 'm';
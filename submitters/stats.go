@@ -0,0 +1,136 @@
+package submitters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/internal/utils"
+)
+
+const (
+	defaultStatsInterval = time.Minute
+	minStatsInterval     = time.Second
+)
+
+// pathStats are the stats of a submitter at some path in the handler tree.
+type pathStats struct {
+	Path  string `json:"path"`
+	Kind  string `json:"kind"`
+	Stats []Stat `json:"stats"`
+}
+
+// walkTree calls fn with every submitter in the configured submitter and the path its handler is
+// served at. Named submitters are visited under their name only, so each submitter is visited
+// once.
+func (s *ConfiguredSubmitter) walkTree(fn func(path string, sub Submitter)) {
+	var walk func(path string, sub Submitter)
+	walk = func(path string, sub Submitter) {
+		if _, ok := sub.(*lateSubmitter); ok {
+			return
+		}
+		sub = unwrap(sub)
+		fn(path, sub)
+		for i, child := range sub.Children() {
+			walk(childPath(path, sub, i), child)
+		}
+	}
+
+	walk("/sub", s.root)
+	for name, late := range s.named {
+		walk("/name/"+name, late.sub)
+	}
+}
+
+// childPath returns the path of the handler for the i'th child of sub at path.
+func childPath(path string, sub Submitter, i int) string {
+	if _, ok := unwrap(sub).(*MultiSubmitter); ok {
+		return path + "/sub/" + strconv.Itoa(i)
+	}
+	return path + "/sub"
+}
+
+// treeStats returns the stats of every submitter in the configured submitter keyed by the path
+// its handler is served at.
+func (s *ConfiguredSubmitter) treeStats() []pathStats {
+	out := []pathStats{{Path: "/", Kind: "ConfiguredSubmitter", Stats: s.Stats()}}
+	s.walkTree(func(path string, sub Submitter) {
+		out = append(out, pathStats{
+			Path:  path,
+			Kind:  reflect.TypeOf(sub).Elem().Name(),
+			Stats: sub.Stats(),
+		})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+func (s *ConfiguredSubmitter) statsHandler() http.Handler {
+	return hmux.Dir{
+		"": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			stats := make(map[string]pathStats)
+			for _, ps := range s.treeStats() {
+				stats[ps.Path] = ps
+			}
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "    ")
+			enc.Encode(stats)
+		}),
+		"/metrics": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+			fmt.Fprintf(w, "# HELP hydrant_submitter_stat Stats reported by pipeline submitters.\n")
+			fmt.Fprintf(w, "# TYPE hydrant_submitter_stat untyped\n")
+			for _, ps := range s.treeStats() {
+				for _, st := range ps.Stats {
+					fmt.Fprintf(w, "hydrant_submitter_stat{path=\"%s\",kind=\"%s\",stat=\"%s\"} %d\n",
+						escapeLabelValue(ps.Path), ps.Kind, st.Name, st.Value)
+				}
+			}
+		}),
+	}
+}
+
+// reportStats periodically submits a hydrant.pipeline.stats event for every submitter to sub
+// until the context is canceled.
+func (s *ConfiguredSubmitter) reportStats(ctx context.Context, sub Submitter, interval time.Duration) {
+	if interval == 0 {
+		interval = defaultStatsInterval
+	}
+	interval = utils.Bound(interval, [2]time.Duration{minStatsInterval, maxGroupInterval})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		s.submitStats(ctx, sub)
+	}
+}
+
+// submitStats submits a hydrant.pipeline.stats event for every submitter to sub.
+func (s *ConfiguredSubmitter) submitStats(ctx context.Context, sub Submitter) {
+	now := time.Now()
+	for _, ps := range s.treeStats() {
+		ev := make(hydrant.Event, 0, 4+len(ps.Stats))
+		ev = append(ev,
+			hydrant.String("name", "hydrant.pipeline.stats"),
+			hydrant.String("path", ps.Path),
+			hydrant.String("kind", ps.Kind),
+			hydrant.Timestamp("timestamp", now),
+		)
+		for _, st := range ps.Stats {
+			ev = append(ev, hydrant.Uint(st.Name, st.Value))
+		}
+		sub.Submit(ctx, ev)
+	}
+}
@@ -0,0 +1,460 @@
+package submitters
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/errs/v2"
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/internal/utils"
+	"storj.io/hydrant/value"
+)
+
+const (
+	defaultStatsDInterval   = time.Second
+	defaultStatsDPacketSize = 1432
+	defaultStatsDBatch      = 1 << 20
+
+	statsDWriteTimeout = time.Second
+)
+
+// statsDQuantiles are the gauges sent for every histogram of a grouped event along with the min,
+// max and avg.
+var statsDQuantiles = []struct {
+	name string
+	q    float64
+}{
+	{"p50", 0.5},
+	{"p90", 0.9},
+	{"p99", 0.99},
+}
+
+// StatsDOptions configures a StatsDSubmitter.
+type StatsDOptions struct {
+	// Address is the host and port of the StatsD daemon, which is sent UDP packets.
+	Address string
+
+	// Prefix is prepended to every metric name, separated by a dot.
+	Prefix string
+
+	// Tags sends the tags in the DogStatsD format. Otherwise the tag values are added to the
+	// metric name, after the prefix, in order.
+	Tags bool
+
+	// TagKeys are the annotations of raw span events sent as tags. The tags of grouped events
+	// are their group keys.
+	TagKeys []string
+
+	// PacketSize is the most bytes of metric lines sent in one packet, defaulting to 1432 to fit
+	// in the MTU of most networks.
+	PacketSize int
+
+	// FlushInterval is how often queued lines are sent, defaulting to a second, and MaxBatchSize
+	// is how many bytes of lines are queued, defaulting to a MiB.
+	FlushInterval time.Duration
+	MaxBatchSize  int
+}
+
+// StatsDSubmitter sends metrics to a StatsD or DogStatsD daemon. It is meant to be fed by a
+// GrouperSubmitter: every histogram of a grouped event is sent as a count and as min, max, avg,
+// p50, p90 and p99 gauges named after its key, tagged with the group keys. The gauges of
+// histograms of durations, which the grouper keeps in seconds, are sent in milliseconds. Raw spans
+// that reach it are sent as timings in milliseconds named after the span, with a count of the
+// failed ones. Other events are skipped. Lines are queued and sent in packets of at most
+// PacketSize bytes.
+type StatsDSubmitter struct {
+	opts StatsDOptions
+	live liveBuffer
+
+	stats struct {
+		received   atomic.Uint64
+		skipped    atomic.Uint64
+		dropped    atomic.Uint64
+		lines      atomic.Uint64
+		packets    atomic.Uint64
+		sendErrors atomic.Uint64
+		lostLines  atomic.Uint64
+	}
+
+	mu      sync.Mutex
+	batch   []byte
+	trigger chan struct{}
+
+	connMu sync.Mutex
+	conn   net.Conn
+}
+
+// NewStatsDSubmitter returns a StatsDSubmitter with the options.
+func NewStatsDSubmitter(opts StatsDOptions) (*StatsDSubmitter, error) {
+	if opts.Address == "" {
+		return nil, errs.Errorf("statsd requires an address")
+	}
+	if _, _, err := net.SplitHostPort(opts.Address); err != nil {
+		return nil, errs.Errorf("invalid statsd address %q: %w", opts.Address, err)
+	}
+
+	opts.Prefix = strings.TrimSuffix(opts.Prefix, ".")
+	if opts.PacketSize <= 0 {
+		opts.PacketSize = defaultStatsDPacketSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultStatsDInterval
+	}
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = defaultStatsDBatch
+	}
+
+	return &StatsDSubmitter{
+		opts:    opts,
+		live:    newLiveBuffer(),
+		trigger: make(chan struct{}, 1),
+	}, nil
+}
+
+func (s *StatsDSubmitter) Children() []Submitter {
+	return []Submitter{}
+}
+
+func (s *StatsDSubmitter) ExtraData() any {
+	return map[string]string{
+		"address": s.opts.Address,
+		"prefix":  s.opts.Prefix,
+	}
+}
+
+func (s *StatsDSubmitter) Run(ctx context.Context) {
+	defer s.close()
+
+	nextTick := time.After(utils.Jitter(s.opts.FlushInterval))
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := drainContext(ctx)
+			defer cancel()
+			_ = s.flush(ctx)
+			return
+		case <-s.trigger:
+		case <-nextTick:
+		}
+		nextTick = time.After(utils.Jitter(s.opts.FlushInterval))
+		_ = s.flush(ctx)
+	}
+}
+
+func (s *StatsDSubmitter) Flush(ctx context.Context) error { return s.flush(ctx) }
+
+func (s *StatsDSubmitter) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (s *StatsDSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	s.live.Record(ev)
+	s.stats.received.Add(1)
+
+	lines := s.appendEvent(nil, ev)
+	if len(lines) == 0 {
+		s.stats.skipped.Add(1)
+		return
+	}
+
+	s.mu.Lock()
+	if len(s.batch)+len(lines) <= s.opts.MaxBatchSize {
+		s.batch = append(s.batch, lines...)
+		s.stats.lines.Add(uint64(bytes.Count(lines, []byte{'\n'})))
+	} else {
+		s.stats.dropped.Add(1)
+	}
+	// trigger a flush slightly early to avoid dropping events.
+	if len(s.batch) >= s.opts.MaxBatchSize*2/3 {
+		s.Trigger()
+	}
+	s.mu.Unlock()
+}
+
+// flush sends the queued lines, packing as many whole lines into each packet as fit. A line
+// longer than the packet size is sent alone. The lines of a packet that fails to send are lost.
+func (s *StatsDSubmitter) flush(ctx context.Context) error {
+	s.mu.Lock()
+	batch := s.batch
+	s.batch = nil
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	var firstErr error
+	for len(batch) > 0 {
+		// find the end of the last line that fits, or the end of the first line.
+		end := 0
+		for i := 0; i < len(batch); {
+			j := bytes.IndexByte(batch[i:], '\n') + i + 1
+			if end > 0 && j-1 > s.opts.PacketSize {
+				break
+			}
+			end, i = j, j
+		}
+
+		if err := s.send(ctx, batch[:end-1]); err != nil {
+			s.stats.lostLines.Add(uint64(bytes.Count(batch[:end], []byte{'\n'})))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		batch = batch[end:]
+	}
+	return firstErr
+}
+
+// send writes the packet, dialing the connection if needed. Must be called with s.connMu held.
+func (s *StatsDSubmitter) send(ctx context.Context, packet []byte) error {
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "udp", s.opts.Address)
+		if err != nil {
+			s.stats.sendErrors.Add(1)
+			return err
+		}
+		s.conn = conn
+	}
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(statsDWriteTimeout))
+	if _, err := s.conn.Write(packet); err != nil {
+		s.stats.sendErrors.Add(1)
+		_ = s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	s.stats.packets.Add(1)
+	return nil
+}
+
+func (s *StatsDSubmitter) close() {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// appendEvent appends the metric lines for the event, each ending in a newline. It appends
+// nothing for events that are neither grouped nor spans.
+func (s *StatsDSubmitter) appendEvent(buf []byte, ev hydrant.Event) []byte {
+	grouped := false
+	for _, a := range ev {
+		if a.Key == "agg:duration" {
+			grouped = true
+			break
+		}
+	}
+
+	if grouped {
+		return s.appendGrouped(buf, ev)
+	}
+	if isSpan(ev) {
+		return s.appendSpan(buf, ev)
+	}
+	return buf
+}
+
+// appendGrouped appends a count and gauges for every histogram of the grouped event, tagged with
+// the group keys, which are the annotations that are neither histograms nor agg metadata. The
+// gauges of the histograms the grouper lists in agg:durations are converted from seconds to
+// milliseconds to match the span timings.
+func (s *StatsDSubmitter) appendGrouped(buf []byte, ev hydrant.Event) []byte {
+	var tags []hydrant.Annotation
+	for _, a := range ev {
+		if a.Value.Kind() == value.KindHistogram || isWeightKey(a.Key) || strings.HasPrefix(a.Key, "agg:") {
+			continue
+		}
+		tags = append(tags, a)
+	}
+
+	durations := aggDurations(ev)
+
+	for _, a := range ev {
+		h, ok := a.Value.Histogram()
+		if !ok || h.Total() == 0 {
+			continue
+		}
+		total, _, avg, _ := h.Summary()
+
+		scale := 1.
+		if _, ok := durations[a.Key]; ok {
+			scale = float64(time.Second / time.Millisecond)
+		}
+
+		buf = s.appendLine(buf, tags, a.Key, "count", float64(total), "c", 1)
+		buf = s.appendLine(buf, tags, a.Key, "min", float64(h.Min())*scale, "g", 1)
+		buf = s.appendLine(buf, tags, a.Key, "max", float64(h.Max())*scale, "g", 1)
+		buf = s.appendLine(buf, tags, a.Key, "avg", avg*scale, "g", 1)
+		for _, q := range statsDQuantiles {
+			buf = s.appendLine(buf, tags, a.Key, q.name, float64(h.Quantile(q.q))*scale, "g", 1)
+		}
+	}
+	return buf
+}
+
+// appendSpan appends a timing in milliseconds for the span, and a count if it failed, sampled at
+// the inverse of the weight of the event and tagged with the configured keys.
+func (s *StatsDSubmitter) appendSpan(buf []byte, ev hydrant.Event) []byte {
+	var name string
+	var dur time.Duration
+	var hasDur, failed bool
+	var tags []hydrant.Annotation
+	for _, a := range ev {
+		switch a.Key {
+		case "name":
+			name, _ = a.Value.String()
+		case "duration":
+			dur, hasDur = a.Value.Duration()
+		case "success":
+			ok, isBool := a.Value.Bool()
+			failed = isBool && !ok
+		}
+	}
+	if name == "" || !hasDur {
+		return buf
+	}
+	for _, key := range s.opts.TagKeys {
+		for _, a := range ev {
+			if a.Key == key {
+				tags = append(tags, a)
+				break
+			}
+		}
+	}
+
+	rate := 1.
+	if weight := eventWeight(ev); weight > 1 {
+		rate = 1 / weight
+	}
+
+	buf = s.appendLine(buf, tags, name, "duration", float64(dur)/float64(time.Millisecond), "ms", rate)
+	if failed {
+		buf = s.appendLine(buf, tags, name, "errors", 1, "c", rate)
+	}
+	return buf
+}
+
+// appendLine appends one metric line named prefix.base.stat, with the tag values between the
+// prefix and base unless tags are enabled. It appends nothing if the value is not finite.
+func (s *StatsDSubmitter) appendLine(
+	buf []byte,
+	tags []hydrant.Annotation,
+	base, stat string,
+	val float64,
+	typ string,
+	rate float64,
+) []byte {
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return buf
+	}
+
+	if s.opts.Prefix != "" {
+		buf = appendStatsDName(buf, s.opts.Prefix)
+		buf = append(buf, '.')
+	}
+	if !s.opts.Tags {
+		for _, t := range tags {
+			if v := consoleValue(t.Value); v != "" {
+				buf = appendStatsDName(buf, v)
+				buf = append(buf, '.')
+			}
+		}
+	}
+	buf = appendStatsDName(buf, base)
+	buf = append(buf, '.')
+	buf = append(buf, stat...)
+
+	buf = append(buf, ':')
+	buf = strconv.AppendFloat(buf, val, 'f', -1, 64)
+	buf = append(buf, '|')
+	buf = append(buf, typ...)
+	if rate < 1 {
+		buf = append(buf, "|@"...)
+		buf = strconv.AppendFloat(buf, rate, 'f', -1, 64)
+	}
+	if s.opts.Tags && len(tags) > 0 {
+		buf = append(buf, "|#"...)
+		for i, t := range tags {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendStatsDTag(buf, t.Key, true)
+			buf = append(buf, ':')
+			buf = appendStatsDTag(buf, consoleValue(t.Value), false)
+		}
+	}
+	return append(buf, '\n')
+}
+
+// appendStatsDName appends the metric name with the characters that delimit a line, and spaces,
+// replaced by _.
+func appendStatsDName(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ':', '|', '@', '#', ',', ' ', '\t', '\n', '\r':
+			buf = append(buf, '_')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+// appendStatsDTag appends the tag key or value with the characters that delimit tags replaced by
+// _. Values may contain colons.
+func appendStatsDTag(buf []byte, s string, key bool) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '|' || c == ',' || c == '#' || c == '\n' || c == '\r' || (key && c == ':'):
+			buf = append(buf, '_')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+func (s *StatsDSubmitter) lostEvents() uint64 {
+	return s.stats.dropped.Load()
+}
+
+func (s *StatsDSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", s.stats.received.Load()},
+		{"skipped", s.stats.skipped.Load()},
+		{"dropped", s.stats.dropped.Load()},
+		{"lines", s.stats.lines.Load()},
+		{"packets", s.stats.packets.Load()},
+		{"send_errors", s.stats.sendErrors.Load()},
+		{"lost_lines", s.stats.lostLines.Load()},
+	}
+}
+
+func (s *StatsDSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(s)),
+		"/live":  s.live.Handler(),
+		"/stats": statsHandler(s.Stats),
+	}
+}
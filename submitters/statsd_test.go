@@ -0,0 +1,112 @@
+package submitters
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/filter"
+)
+
+func TestStatsD(t *testing.T) {
+	listen := func(t *testing.T) (net.PacketConn, func() string) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = pc.Close() })
+		return pc, func() string {
+			_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buf := make([]byte, 65536)
+			n, _, err := pc.ReadFrom(buf)
+			assert.NoError(t, err)
+			return string(buf[:n])
+		}
+	}
+	span := func(name string, dur time.Duration, success bool, extra ...hydrant.Annotation) hydrant.Event {
+		return append(hydrant.Event{
+			hydrant.String("name", name),
+			hydrant.SpanId("span_id", [8]byte{1}),
+			hydrant.SpanId("parent_id", [8]byte{2}),
+			hydrant.Duration("duration", dur),
+			hydrant.Bool("success", success),
+		}, extra...)
+	}
+
+	t.Run("Grouped", func(t *testing.T) {
+		pc, read := listen(t)
+		s, err := NewStatsDSubmitter(StatsDOptions{Address: pc.LocalAddr().String(), Prefix: "app.", Tags: true})
+		assert.NoError(t, err)
+		g := NewGrouperSubmitter([]string{"name"}, time.Minute, s)
+
+		g.Submit(t.Context(), span("req", time.Second, true, hydrant.Duration("queued", 2*time.Second)))
+		g.Submit(t.Context(), span("req", time.Second, false, hydrant.Int("size", 2)))
+		assert.NoError(t, g.Flush(t.Context()))
+
+		lines := strings.Split(read(), "\n")
+		assert.That(t, slices.Contains(lines, "app.duration.count:2|c|#name:req"))
+		assert.That(t, slices.Contains(lines, "app.duration.min:1000|g|#name:req"))
+		assert.That(t, slices.Contains(lines, "app.success.count:2|c|#name:req"))
+		assert.That(t, slices.Contains(lines, "app.success.min:0|g|#name:req"))
+
+		// every histogram of durations is sent in milliseconds, whatever its key.
+		assert.That(t, slices.Contains(lines, "app.queued.min:2000|g|#name:req"))
+		assert.That(t, slices.Contains(lines, "app.size.min:2|g|#name:req"))
+	})
+
+	t.Run("Spans", func(t *testing.T) {
+		pc, read := listen(t)
+		s, err := NewStatsDSubmitter(StatsDOptions{Address: pc.LocalAddr().String(), Prefix: "app", TagKeys: []string{"host"}})
+		assert.NoError(t, err)
+
+		s.Submit(t.Context(), span("db.query", 1500*time.Microsecond, false,
+			hydrant.String("host", "a|b"),
+			hydrant.Float("sample_rate", 4),
+		))
+		s.Submit(t.Context(), hydrant.Event{hydrant.String("message", "not a metric")})
+		assert.NoError(t, s.Flush(t.Context()))
+
+		assert.Equal(t, read(), "app.a_b.db.query.duration:1.5|ms|@0.25\napp.a_b.db.query.errors:1|c|@0.25")
+		assert.Equal(t, s.Stats()[1], Stat{"skipped", 1})
+	})
+
+	t.Run("Packets", func(t *testing.T) {
+		pc, read := listen(t)
+		s, err := NewStatsDSubmitter(StatsDOptions{Address: pc.LocalAddr().String(), PacketSize: 64})
+		assert.NoError(t, err)
+
+		for i := range 10 {
+			s.Submit(t.Context(), span(fmt.Sprint("s", i), time.Millisecond, true))
+		}
+		assert.NoError(t, s.Flush(t.Context()))
+
+		var got []string
+		for len(got) < 10 {
+			packet := read()
+			assert.That(t, len(packet) <= 64)
+			got = append(got, strings.Split(packet, "\n")...)
+		}
+		assert.Equal(t, len(got), 10)
+		assert.Equal(t, got[9], "s9.duration:1|ms")
+		assert.Equal(t, s.Stats()[4], Stat{"packets", 4})
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		var cfg config.Config
+		assert.NoError(t, json.Unmarshal([]byte(`{"submitter": {
+			"kind": "statsd", "address": "127.0.0.1:8125", "prefix": "app", "tags": true, "packet_size": 512
+		}}`), &cfg))
+
+		sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+		assert.NoError(t, err)
+		s := sub.root.(*StatsDSubmitter)
+		assert.Equal(t, s.opts.PacketSize, 512)
+		assert.That(t, s.opts.Tags)
+	})
+}
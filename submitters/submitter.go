@@ -3,13 +3,29 @@ package submitters
 import (
 	"context"
 	"net/http"
+	"time"
+
+	"github.com/zeebo/errs/v2"
 
 	"storj.io/hydrant"
 )
 
+// drainTimeout bounds the final flush a submitter does when the context passed to its Run method
+// is canceled.
+const drainTimeout = 30 * time.Second
+
 type Submitter interface {
 	hydrant.Submitter
 
+	// Flush sends any buffered events to their destination, and then flushes the children in
+	// order. This means aggregating submitters like groupers flush before the exporters below
+	// them. It returns early with an error if the context is canceled.
+	Flush(ctx context.Context) error
+
+	// Stats returns the current values of the submitter's counters, like how many events it has
+	// received or dropped, and gauges, like how many groups it holds.
+	Stats() []Stat
+
 	Handler() http.Handler
 	Children() []Submitter
 	ExtraData() any
@@ -18,3 +34,21 @@ type Submitter interface {
 type runnable interface {
 	Run(context.Context)
 }
+
+// flushChildren flushes the children of sub in order, continuing past errors.
+func flushChildren(ctx context.Context, sub Submitter) error {
+	var group errs.Group
+	for _, child := range sub.Children() {
+		if err := ctx.Err(); err != nil {
+			group.Append(err)
+			break
+		}
+		group.Append(child.Flush(ctx))
+	}
+	return group.Err()
+}
+
+// drainContext returns a context for the final flush after ctx is canceled.
+func drainContext(ctx context.Context) (context.Context, func()) {
+	return context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+}
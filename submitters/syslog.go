@@ -0,0 +1,515 @@
+package submitters
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zeebo/errs/v2"
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/internal/utils"
+)
+
+const (
+	defaultSyslogInterval = time.Second
+	defaultSyslogBatch    = 1000
+	defaultSyslogSDID     = "hydrant@32473"
+
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 5 * time.Second
+	syslogMinBackoff   = 100 * time.Millisecond
+	syslogMaxBackoff   = 30 * time.Second
+
+	// maxSyslogDatagram is the largest message sent over UDP or a unix datagram socket. Longer
+	// messages are truncated.
+	maxSyslogDatagram = 65507
+)
+
+// syslogLocalPaths are tried in order for the local syslog socket.
+var syslogLocalPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSkipKeys are annotations that are part of the syslog header instead of the structured
+// data.
+var syslogSkipKeys = []string{"message", "timestamp", "level"}
+
+// SyslogOptions configures a SyslogSubmitter.
+type SyslogOptions struct {
+	// Network is udp, tcp or unix. With unix, Address is the path of the local syslog socket,
+	// which defaults to the usual locations, and both datagram and stream sockets are tried.
+	Network string
+	Address string
+
+	// Facility is the syslog facility name, defaulting to user.
+	Facility string
+
+	// Hostname and AppName identify the sender, defaulting to the hostname and the name of the
+	// executable.
+	Hostname string
+	AppName  string
+
+	// SDID is the id of the structured data element holding the annotations. It defaults to
+	// hydrant@32473.
+	SDID string
+
+	// FlushInterval is how often queued messages are sent, defaulting to a second, and
+	// MaxBatchSize is how many messages are queued, defaulting to 1000.
+	FlushInterval time.Duration
+	MaxBatchSize  int
+}
+
+// SyslogSubmitter sends log events, those with a message annotation, to a syslog daemon as RFC
+// 5424 messages. The level annotation decides the severity, and the other annotations are sent as
+// the parameters of a structured data element. Messages are sent over UDP, TCP with octet
+// counting framing, or the local unix socket. If the connection fails, it is redialed with
+// exponential backoff, and the unsent messages are kept as long as there is room in the queue.
+type SyslogSubmitter struct {
+	opts     SyslogOptions
+	facility int
+	procID   string
+	live     liveBuffer
+
+	stats struct {
+		received   atomic.Uint64
+		skipped    atomic.Uint64
+		dropped    atomic.Uint64
+		sent       atomic.Uint64
+		sendErrors atomic.Uint64
+		dials      atomic.Uint64
+		dialErrors atomic.Uint64
+		lost       atomic.Uint64
+	}
+
+	mu      sync.Mutex
+	batch   []hydrant.Event
+	trigger chan struct{}
+
+	connMu   sync.Mutex
+	conn     net.Conn
+	stream   bool
+	failures int
+	nextDial time.Time
+	buf      []byte
+}
+
+// NewSyslogSubmitter returns a SyslogSubmitter with the options.
+func NewSyslogSubmitter(opts SyslogOptions) (*SyslogSubmitter, error) {
+	switch opts.Network {
+	case "udp", "tcp":
+		if opts.Address == "" {
+			return nil, errs.Errorf("syslog network %q requires an address", opts.Network)
+		}
+	case "unix":
+	default:
+		return nil, errs.Errorf("unknown syslog network %q", opts.Network)
+	}
+
+	if opts.Facility == "" {
+		opts.Facility = "user"
+	}
+	facility, ok := syslogFacilities[opts.Facility]
+	if !ok {
+		return nil, errs.Errorf("unknown syslog facility %q", opts.Facility)
+	}
+
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.SDID == "" {
+		opts.SDID = defaultSyslogSDID
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultSyslogInterval
+	}
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = defaultSyslogBatch
+	}
+
+	return &SyslogSubmitter{
+		opts:     opts,
+		facility: facility,
+		procID:   strconv.Itoa(os.Getpid()),
+		live:     newLiveBuffer(),
+		batch:    make([]hydrant.Event, 0, opts.MaxBatchSize),
+		trigger:  make(chan struct{}, 1),
+	}, nil
+}
+
+func (s *SyslogSubmitter) Children() []Submitter {
+	return []Submitter{}
+}
+
+func (s *SyslogSubmitter) ExtraData() any {
+	return map[string]string{
+		"network":  s.opts.Network,
+		"address":  s.opts.Address,
+		"facility": s.opts.Facility,
+		"app_name": s.opts.AppName,
+	}
+}
+
+func (s *SyslogSubmitter) Run(ctx context.Context) {
+	defer s.close()
+
+	nextTick := time.After(utils.Jitter(s.opts.FlushInterval))
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := drainContext(ctx)
+			defer cancel()
+			_ = s.flush(ctx)
+
+			// whatever is still queued after the last flush will never be sent.
+			s.mu.Lock()
+			s.stats.lost.Add(uint64(len(s.batch)))
+			s.batch = s.batch[:0]
+			s.mu.Unlock()
+			return
+		case <-s.trigger:
+		case <-nextTick:
+		}
+		nextTick = time.After(utils.Jitter(s.opts.FlushInterval))
+		_ = s.flush(ctx)
+	}
+}
+
+func (s *SyslogSubmitter) Flush(ctx context.Context) error { return s.flush(ctx) }
+
+func (s *SyslogSubmitter) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (s *SyslogSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	s.live.Record(ev)
+	s.stats.received.Add(1)
+
+	if !slices.ContainsFunc(ev, func(a hydrant.Annotation) bool { return a.Key == "message" }) {
+		s.stats.skipped.Add(1)
+		return
+	}
+
+	s.mu.Lock()
+	if len(s.batch) < cap(s.batch) {
+		s.batch = append(s.batch, ev)
+	} else {
+		s.stats.dropped.Add(1)
+	}
+	// trigger a flush slightly early to avoid dropping events.
+	if len(s.batch) >= cap(s.batch)*2/3 {
+		s.Trigger()
+	}
+	s.mu.Unlock()
+}
+
+// flush sends the queued messages. If the connection fails, the unsent messages are queued again
+// in front of any newer ones, and the ones that no longer fit are lost.
+func (s *SyslogSubmitter) flush(ctx context.Context) error {
+	s.mu.Lock()
+	batch := slices.Clone(s.batch)
+	s.batch = s.batch[:0]
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	for i, ev := range batch {
+		if err := s.send(ctx, ev); err != nil {
+			s.requeue(batch[i:])
+			return err
+		}
+		s.stats.sent.Add(1)
+	}
+	return nil
+}
+
+// requeue puts the events back at the front of the queue.
+func (s *SyslogSubmitter) requeue(events []hydrant.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queued := append(events, s.batch...)
+	if over := len(queued) - cap(s.batch); over > 0 {
+		s.stats.lost.Add(uint64(over))
+		queued = queued[:cap(s.batch)]
+	}
+	s.batch = append(s.batch[:0], queued...)
+}
+
+// send writes the event to the connection, dialing it if needed. Must be called with s.connMu held.
+func (s *SyslogSubmitter) send(ctx context.Context, ev hydrant.Event) error {
+	if s.conn == nil {
+		if err := s.dial(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.buf = s.buf[:0]
+	if s.stream {
+		msg := s.appendMessage(nil, ev)
+		s.buf = strconv.AppendInt(s.buf, int64(len(msg)), 10)
+		s.buf = append(s.buf, ' ')
+		s.buf = append(s.buf, msg...)
+	} else {
+		s.buf = s.appendMessage(s.buf, ev)
+		if len(s.buf) > maxSyslogDatagram {
+			s.buf = s.buf[:maxSyslogDatagram]
+		}
+	}
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	if _, err := s.conn.Write(s.buf); err != nil {
+		s.stats.sendErrors.Add(1)
+		_ = s.conn.Close()
+		s.conn = nil
+		s.backoff()
+		return err
+	}
+	return nil
+}
+
+// dial connects to the syslog daemon unless it is backing off from a failure. Must be called with
+// s.connMu held.
+func (s *SyslogSubmitter) dial(ctx context.Context) error {
+	if time.Now().Before(s.nextDial) {
+		return errs.Errorf("syslog: backing off after %d failures", s.failures)
+	}
+	s.stats.dials.Add(1)
+
+	d := net.Dialer{Timeout: syslogDialTimeout}
+
+	var conn net.Conn
+	var err error
+	switch s.opts.Network {
+	case "unix":
+		paths := syslogLocalPaths
+		if s.opts.Address != "" {
+			paths = []string{s.opts.Address}
+		}
+	dial:
+		for _, path := range paths {
+			for _, network := range []string{"unixgram", "unix"} {
+				conn, err = d.DialContext(ctx, network, path)
+				if err == nil {
+					s.stream = network == "unix"
+					break dial
+				}
+			}
+		}
+	default:
+		conn, err = d.DialContext(ctx, s.opts.Network, s.opts.Address)
+		s.stream = s.opts.Network == "tcp"
+	}
+	if err != nil {
+		s.stats.dialErrors.Add(1)
+		s.backoff()
+		return err
+	}
+
+	s.conn, s.failures, s.nextDial = conn, 0, time.Time{}
+	return nil
+}
+
+// backoff delays the next dial exponentially in the number of consecutive failures. Must be
+// called with s.connMu held.
+func (s *SyslogSubmitter) backoff() {
+	delay := syslogMinBackoff << min(s.failures, 16)
+	s.failures++
+	s.nextDial = time.Now().Add(utils.Jitter(min(delay, syslogMaxBackoff)))
+}
+
+func (s *SyslogSubmitter) close() {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// appendMessage appends the event formatted as an RFC 5424 message.
+func (s *SyslogSubmitter) appendMessage(buf []byte, ev hydrant.Event) []byte {
+	var message string
+	ts := time.Now()
+	severity := 6
+	for _, a := range ev {
+		switch a.Key {
+		case "message":
+			message = consoleValue(a.Value)
+		case "timestamp":
+			if t, ok := a.Value.Timestamp(); ok {
+				ts = t
+			}
+		case "level":
+			if sev, ok := syslogSeverity(consoleValue(a.Value)); ok {
+				severity = sev
+			}
+		}
+	}
+
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(s.facility*8+severity), 10)
+	buf = append(buf, ">1 "...)
+	buf = ts.UTC().AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	buf = append(buf, ' ')
+	buf = appendSyslogHeader(buf, s.opts.Hostname, 255)
+	buf = append(buf, ' ')
+	buf = appendSyslogHeader(buf, s.opts.AppName, 48)
+	buf = append(buf, ' ')
+	buf = appendSyslogHeader(buf, s.procID, 128)
+	buf = append(buf, " - "...)
+
+	sd := len(buf)
+	buf = append(buf, '[')
+	buf = append(buf, s.opts.SDID...)
+	for _, a := range ev {
+		if slices.Contains(syslogSkipKeys, a.Key) {
+			continue
+		}
+		buf = append(buf, ' ')
+		buf = appendSyslogName(buf, a.Key)
+		buf = append(buf, `="`...)
+		buf = appendSyslogParam(buf, consoleValue(a.Value))
+		buf = append(buf, '"')
+	}
+	if len(buf) == sd+1+len(s.opts.SDID) {
+		buf = append(buf[:sd], '-')
+	} else {
+		buf = append(buf, ']')
+	}
+
+	if message != "" {
+		buf = append(buf, ' ')
+		buf = append(buf, message...)
+	}
+	return buf
+}
+
+// syslogSeverity returns the severity for the level name, compared without case and any +N or -N
+// suffix like slog adds.
+func syslogSeverity(level string) (int, bool) {
+	level = strings.ToLower(level)
+	if i := strings.IndexAny(level, "+-"); i > 0 {
+		level = level[:i]
+	}
+	switch level {
+	case "emerg", "emergency", "panic":
+		return 0, true
+	case "alert":
+		return 1, true
+	case "crit", "critical", "fatal":
+		return 2, true
+	case "err", "error":
+		return 3, true
+	case "warn", "warning":
+		return 4, true
+	case "notice":
+		return 5, true
+	case "info", "informational":
+		return 6, true
+	case "debug", "trace":
+		return 7, true
+	}
+	return 0, false
+}
+
+// appendSyslogHeader appends the header field, which is printable ASCII of at most n bytes, or -
+// if it is empty.
+func appendSyslogHeader(buf []byte, s string, n int) []byte {
+	start := len(buf)
+	for i := 0; i < len(s) && len(buf)-start < n; i++ {
+		if c := s[i]; c > ' ' && c < 0x7f {
+			buf = append(buf, c)
+		}
+	}
+	if len(buf) == start {
+		buf = append(buf, '-')
+	}
+	return buf
+}
+
+// appendSyslogName appends the structured data parameter name, which is at most 32 printable
+// ASCII characters other than =, ], " and space. Other characters are replaced by _.
+func appendSyslogName(buf []byte, s string) []byte {
+	start := len(buf)
+	for _, r := range s {
+		if len(buf)-start >= 32 {
+			break
+		}
+		if r <= ' ' || r >= 0x7f || r == '=' || r == ']' || r == '"' {
+			r = '_'
+		}
+		buf = append(buf, byte(r))
+	}
+	if len(buf) == start {
+		buf = append(buf, '_')
+	}
+	return buf
+}
+
+// appendSyslogParam appends the structured data parameter value with ", \ and ] escaped.
+func appendSyslogParam(buf []byte, s string) []byte {
+	for _, r := range s {
+		switch r {
+		case '"', '\\', ']':
+			buf = append(buf, '\\', byte(r))
+		case utf8.RuneError:
+			buf = append(buf, "�"...)
+		default:
+			buf = utf8.AppendRune(buf, r)
+		}
+	}
+	return buf
+}
+
+func (s *SyslogSubmitter) lostEvents() uint64 {
+	return s.stats.dropped.Load() + s.stats.lost.Load()
+}
+
+func (s *SyslogSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", s.stats.received.Load()},
+		{"skipped", s.stats.skipped.Load()},
+		{"dropped", s.stats.dropped.Load()},
+		{"sent", s.stats.sent.Load()},
+		{"send_errors", s.stats.sendErrors.Load()},
+		{"dials", s.stats.dials.Load()},
+		{"dial_errors", s.stats.dialErrors.Load()},
+		{"lost", s.stats.lost.Load()},
+	}
+}
+
+func (s *SyslogSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(s)),
+		"/live":  s.live.Handler(),
+		"/stats": statsHandler(s.Stats),
+	}
+}
@@ -0,0 +1,135 @@
+package submitters
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+)
+
+func TestSyslog(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	ev := hydrant.Event{
+		hydrant.String("message", "disk full"),
+		hydrant.Timestamp("timestamp", ts),
+		hydrant.String("level", "ERROR+2"),
+		hydrant.String("path", `/a "b"]`),
+		hydrant.Int("free bytes", 0),
+	}
+	opts := func(network, addr string) SyslogOptions {
+		return SyslogOptions{
+			Network:  network,
+			Address:  addr,
+			Facility: "local0",
+			Hostname: "host",
+			AppName:  "app",
+		}
+	}
+	want := func(s *SyslogSubmitter) string {
+		return `<131>1 2026-01-02T03:04:05.123456Z host app ` + s.procID +
+			` - [hydrant@32473 path="/a \"b\"\]" free_bytes="0"] disk full`
+	}
+
+	t.Run("UDP", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer func() { _ = pc.Close() }()
+
+		s, err := NewSyslogSubmitter(opts("udp", pc.LocalAddr().String()))
+		assert.NoError(t, err)
+		s.Submit(t.Context(), ev)
+		s.Submit(t.Context(), hydrant.Event{hydrant.String("name", "span")})
+		assert.NoError(t, s.Flush(t.Context()))
+
+		buf := make([]byte, 1024)
+		n, _, err := pc.ReadFrom(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, string(buf[:n]), want(s))
+	})
+
+	t.Run("TCP", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer func() { _ = ln.Close() }()
+
+		s, err := NewSyslogSubmitter(opts("tcp", ln.Addr().String()))
+		assert.NoError(t, err)
+		s.Submit(t.Context(), ev)
+		s.Submit(t.Context(), hydrant.Event{hydrant.String("message", "plain")})
+		assert.NoError(t, s.Flush(t.Context()))
+
+		conn, err := ln.Accept()
+		assert.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		first, second := want(s), "<134>1 "
+		data := make([]byte, 0, 1024)
+		for !strings.Contains(string(data), "plain") {
+			buf := make([]byte, 1024)
+			n, err := conn.Read(buf)
+			assert.NoError(t, err)
+			data = append(data, buf[:n]...)
+		}
+		prefix := fmt.Sprintf("%d %s", len(first), first)
+		assert.That(t, strings.HasPrefix(string(data), prefix))
+		rest := string(data[len(prefix):])
+		size, msg, _ := strings.Cut(rest, " ")
+		assert.Equal(t, size, strconv.Itoa(len(msg)))
+		assert.That(t, strings.HasPrefix(msg, second))
+		assert.That(t, strings.HasSuffix(msg, " - - plain"))
+	})
+
+	t.Run("Unix", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log")
+		s, err := NewSyslogSubmitter(opts("unix", path))
+		assert.NoError(t, err)
+
+		// with nothing listening, the message is kept and the dial is retried after a backoff.
+		s.Submit(t.Context(), ev)
+		assert.Error(t, s.Flush(t.Context()))
+		assert.Error(t, s.Flush(t.Context()))
+
+		pc, err := net.ListenPacket("unixgram", path)
+		assert.NoError(t, err)
+		defer func() { _ = pc.Close() }()
+
+		for s.Flush(t.Context()) != nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		buf := make([]byte, 1024)
+		n, _, err := pc.ReadFrom(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, string(buf[:n]), want(s))
+
+		stats := make(map[string]uint64)
+		for _, st := range s.Stats() {
+			stats[st.Name] = st.Value
+		}
+		assert.Equal(t, stats["sent"], uint64(1))
+		assert.Equal(t, stats["lost"], uint64(0))
+		assert.That(t, stats["dial_errors"] >= 1)
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		s, err := NewSyslogSubmitter(opts("unix", filepath.Join(t.TempDir(), "log")))
+		assert.NoError(t, err)
+
+		// messages that can't be sent by the final flush are lost.
+		s.Submit(t.Context(), ev)
+		s.Submit(t.Context(), ev)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		s.Run(ctx)
+		assert.Equal(t, s.lostEvents(), uint64(2))
+	})
+}
@@ -0,0 +1,404 @@
+package submitters
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/filter"
+	"storj.io/hydrant/value"
+)
+
+const (
+	defaultTailTimeout   = 30 * time.Second
+	defaultTailMaxTraces = 10000
+	defaultTailMaxSpans  = 1000
+)
+
+// TailPolicy decides which traces a TailSamplerSubmitter keeps. A trace is kept if any of the
+// enabled policies match it.
+type TailPolicy struct {
+	// Errors keeps traces with any event that has success=false.
+	Errors bool
+
+	// MinDuration keeps traces whose root span took at least this long, if positive.
+	MinDuration time.Duration
+
+	// Filter keeps traces with any event that passes it, if set.
+	Filter *filter.Filter
+
+	// Probability keeps this fraction of the other traces. Their events are stamped with a
+	// sample_rate so counts can be re-weighted.
+	Probability float64
+}
+
+type tailTrace struct {
+	events  []hydrant.Event
+	first   time.Time
+	root    bool
+	rootDur time.Duration
+	failed  bool
+	matched bool
+}
+
+// TailSamplerSubmitter buffers the events of every trace until its root span completes or it times
+// out, then decides whether to keep it with a TailPolicy and forwards every event of the kept
+// traces. Events that arrive after their trace is decided follow the decision, and events
+// without a trace_id are forwarded immediately.
+type TailSamplerSubmitter struct {
+	policy    TailPolicy
+	timeout   time.Duration
+	maxTraces int
+	maxSpans  int
+	sub       Submitter
+	live      liveBuffer
+
+	stats struct {
+		received     atomic.Uint64
+		passthrough  atomic.Uint64
+		kept         atomic.Uint64
+		dropped      atomic.Uint64
+		keptError    atomic.Uint64
+		keptSlow     atomic.Uint64
+		keptFilter   atomic.Uint64
+		keptBaseline atomic.Uint64
+		timedOut     atomic.Uint64
+		evicted      atomic.Uint64
+		spansDropped atomic.Uint64
+	}
+
+	wait    lockTimer
+	mu      sync.Mutex
+	pending map[[16]byte]*tailTrace
+	order   [][16]byte // pending trace ids by arrival, possibly including decided ones
+
+	decided  map[[16]byte]float64 // trace id → sample rate of kept traces, or zero if dropped
+	decision [][16]byte           // ring of the ids in decided
+	decPos   int
+}
+
+// NewTailSamplerSubmitter returns a tail sampler that waits at most timeout for the root span of
+// a trace, buffers at most maxTraces traces of at most maxSpans events each, and forwards the
+// traces kept by policy to sub. Non-positive values use the defaults of 30s, 10000 traces and
+// 1000 events. When the buffer is full, the oldest trace is decided early.
+func NewTailSamplerSubmitter(
+	policy TailPolicy,
+	timeout time.Duration,
+	maxTraces int,
+	maxSpans int,
+	sub Submitter,
+) *TailSamplerSubmitter {
+	if timeout <= 0 {
+		timeout = defaultTailTimeout
+	}
+	if maxTraces <= 0 {
+		maxTraces = defaultTailMaxTraces
+	}
+	if maxSpans <= 0 {
+		maxSpans = defaultTailMaxSpans
+	}
+	policy.Probability = min(max(policy.Probability, 0), 1)
+
+	return &TailSamplerSubmitter{
+		policy:    policy,
+		timeout:   timeout,
+		maxTraces: maxTraces,
+		maxSpans:  maxSpans,
+		sub:       sub,
+		live:      newLiveBuffer(),
+		pending:   make(map[[16]byte]*tailTrace),
+		decided:   make(map[[16]byte]float64),
+		decision:  make([][16]byte, maxTraces),
+	}
+}
+
+func (t *TailSamplerSubmitter) Children() []Submitter {
+	return []Submitter{t.sub}
+}
+
+func (t *TailSamplerSubmitter) ExtraData() any {
+	extra := map[string]string{
+		"timeout":     t.timeout.String(),
+		"errors":      strconv.FormatBool(t.policy.Errors),
+		"probability": strconv.FormatFloat(t.policy.Probability, 'g', -1, 64),
+	}
+	if t.policy.MinDuration > 0 {
+		extra["min_duration"] = t.policy.MinDuration.String()
+	}
+	if t.policy.Filter != nil {
+		extra["filter"] = t.policy.Filter.Filter()
+	}
+	return extra
+}
+
+func (t *TailSamplerSubmitter) lockWait() *lockTimer { return &t.wait }
+
+func (t *TailSamplerSubmitter) Run(ctx context.Context) {
+	ticker := time.NewTicker(max(t.timeout/4, 100*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := drainContext(ctx)
+			defer cancel()
+			t.decideAll(ctx)
+			return
+
+		case <-ticker.C:
+			t.expire(ctx, time.Now())
+		}
+	}
+}
+
+// Flush decides every pending trace without waiting for its root span and then flushes the child
+// submitter.
+func (t *TailSamplerSubmitter) Flush(ctx context.Context) error {
+	t.decideAll(ctx)
+	return flushChildren(ctx, t)
+}
+
+func (t *TailSamplerSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	t.live.Record(ev)
+	t.stats.received.Add(1)
+
+	traceID, ok := eventTraceID(ev)
+	if !ok {
+		t.stats.passthrough.Add(1)
+		t.sub.Submit(ctx, ev)
+		return
+	}
+
+	t.wait.lock(&t.mu)
+
+	if rate, ok := t.decided[traceID]; ok {
+		t.mu.Unlock()
+		if rate > 0 {
+			t.forward(ctx, []hydrant.Event{ev}, rate)
+		}
+		return
+	}
+
+	var out []tailDecision
+	tr := t.pending[traceID]
+	if tr == nil {
+		if len(t.pending) >= t.maxTraces {
+			out = t.evictOldest(out)
+		}
+		tr = &tailTrace{first: time.Now()}
+		t.pending[traceID] = tr
+		t.order = append(t.order, traceID)
+		t.compactOrder()
+	}
+
+	if len(tr.events) < t.maxSpans {
+		tr.events = append(tr.events, ev)
+	} else {
+		t.stats.spansDropped.Add(1)
+	}
+	t.observe(tr, ev)
+
+	if tr.root {
+		out = append(out, t.decide(traceID, tr))
+	}
+	t.mu.Unlock()
+
+	t.forwardAll(ctx, out)
+}
+
+// observe updates the policy state of the trace with the event. Must be called with t.mu held.
+func (t *TailSamplerSubmitter) observe(tr *tailTrace, ev hydrant.Event) {
+	var spanID, parentID [8]byte
+	var hasSpan, hasParent bool
+	for _, a := range ev {
+		switch a.Key {
+		case "success":
+			if ok, isBool := a.Value.Bool(); isBool && !ok {
+				tr.failed = true
+			}
+		case "span_id":
+			spanID, hasSpan = a.Value.SpanId()
+		case "parent_id":
+			parentID, hasParent = a.Value.SpanId()
+		}
+	}
+	if hasSpan && hasParent && spanID == parentID {
+		tr.root = true
+		for _, a := range ev {
+			if a.Key == "duration" {
+				tr.rootDur, _ = a.Value.Duration()
+			}
+		}
+	}
+
+	if t.policy.Filter != nil && !tr.matched {
+		es := filterEvalPool.Get().(*filter.EvalState)
+		tr.matched = es.Evaluate(t.policy.Filter, ev)
+		filterEvalPool.Put(es)
+	}
+}
+
+type tailDecision struct {
+	events []hydrant.Event
+	rate   float64
+}
+
+// decide applies the policy to the trace and records the decision. Must be called with t.mu held.
+func (t *TailSamplerSubmitter) decide(traceID [16]byte, tr *tailTrace) tailDecision {
+	delete(t.pending, traceID)
+
+	var rate float64
+	switch {
+	case t.policy.Errors && tr.failed:
+		rate = 1
+		t.stats.keptError.Add(1)
+	case t.policy.MinDuration > 0 && tr.root && tr.rootDur >= t.policy.MinDuration:
+		rate = 1
+		t.stats.keptSlow.Add(1)
+	case tr.matched:
+		rate = 1
+		t.stats.keptFilter.Add(1)
+	case t.policy.Probability > 0 && traceFraction(traceID) < t.policy.Probability:
+		rate = 1 / t.policy.Probability
+		t.stats.keptBaseline.Add(1)
+	}
+
+	if old := t.decision[t.decPos]; old != ([16]byte{}) {
+		delete(t.decided, old)
+	}
+	t.decision[t.decPos] = traceID
+	t.decPos = (t.decPos + 1) % len(t.decision)
+	t.decided[traceID] = rate
+
+	if rate == 0 {
+		t.stats.dropped.Add(1)
+		return tailDecision{}
+	}
+	t.stats.kept.Add(1)
+	return tailDecision{events: tr.events, rate: rate}
+}
+
+// compactOrder removes the ids of decided traces from order once they outnumber the pending ones,
+// so it stays bounded by maxTraces even while an old trace waits for its root. Must be called
+// with t.mu held.
+func (t *TailSamplerSubmitter) compactOrder() {
+	if len(t.order) <= 2*len(t.pending) {
+		return
+	}
+	t.order = slices.DeleteFunc(t.order, func(id [16]byte) bool {
+		_, ok := t.pending[id]
+		return !ok
+	})
+}
+
+// evictOldest decides the oldest pending trace early. Must be called with t.mu held.
+func (t *TailSamplerSubmitter) evictOldest(out []tailDecision) []tailDecision {
+	for len(t.order) > 0 {
+		id := t.order[0]
+		t.order = t.order[1:]
+		if tr, ok := t.pending[id]; ok {
+			t.stats.evicted.Add(1)
+			return append(out, t.decide(id, tr))
+		}
+	}
+	return out
+}
+
+// expire decides the traces that have waited longer than the timeout for their root span.
+func (t *TailSamplerSubmitter) expire(ctx context.Context, now time.Time) {
+	var out []tailDecision
+
+	t.mu.Lock()
+	for len(t.order) > 0 {
+		id := t.order[0]
+		tr, ok := t.pending[id]
+		if ok && now.Sub(tr.first) < t.timeout {
+			break
+		}
+		t.order = t.order[1:]
+		if ok {
+			t.stats.timedOut.Add(1)
+			out = append(out, t.decide(id, tr))
+		}
+	}
+	t.mu.Unlock()
+
+	t.forwardAll(ctx, out)
+}
+
+// decideAll decides every pending trace.
+func (t *TailSamplerSubmitter) decideAll(ctx context.Context) {
+	var out []tailDecision
+
+	t.mu.Lock()
+	for _, id := range t.order {
+		if tr, ok := t.pending[id]; ok {
+			out = append(out, t.decide(id, tr))
+		}
+	}
+	t.order = nil
+	t.mu.Unlock()
+
+	t.forwardAll(ctx, out)
+}
+
+func (t *TailSamplerSubmitter) forwardAll(ctx context.Context, out []tailDecision) {
+	for _, d := range out {
+		t.forward(ctx, d.events, d.rate)
+	}
+}
+
+func (t *TailSamplerSubmitter) forward(ctx context.Context, events []hydrant.Event, rate float64) {
+	for _, ev := range events {
+		if rate != 1 {
+			ev = withSampleRate(ev, rate)
+		}
+		t.sub.Submit(ctx, ev)
+	}
+}
+
+func (t *TailSamplerSubmitter) Stats() []Stat {
+	t.mu.Lock()
+	pending := uint64(len(t.pending))
+	t.mu.Unlock()
+	return []Stat{
+		{"received", t.stats.received.Load()},
+		{"passthrough", t.stats.passthrough.Load()},
+		{"pending_traces", pending},
+		{"kept", t.stats.kept.Load()},
+		{"dropped", t.stats.dropped.Load()},
+		{"kept_error", t.stats.keptError.Load()},
+		{"kept_slow", t.stats.keptSlow.Load()},
+		{"kept_filter", t.stats.keptFilter.Load()},
+		{"kept_baseline", t.stats.keptBaseline.Load()},
+		{"timed_out", t.stats.timedOut.Load()},
+		{"evicted", t.stats.evicted.Load()},
+		{"spans_dropped", t.stats.spansDropped.Load()},
+	}
+}
+
+func (t *TailSamplerSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(t)),
+		"/live":  t.live.Handler(),
+		"/sub":   t.sub.Handler(),
+		"/stats": statsHandler(t.Stats),
+	}
+}
+
+// eventTraceID returns the trace_id of the event.
+func eventTraceID(ev hydrant.Event) ([16]byte, bool) {
+	for _, a := range ev {
+		if a.Key == "trace_id" && a.Value.Kind() == value.KindTraceId {
+			return a.Value.TraceId()
+		}
+	}
+	return [16]byte{}, false
+}
@@ -0,0 +1,64 @@
+package submitters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+)
+
+func TestTailSampler(t *testing.T) {
+	null := NewNullSubmitter()
+	ts := NewTailSamplerSubmitter(TailPolicy{Errors: true, MinDuration: time.Second}, time.Minute, 0, 0, null)
+
+	span := func(trace, id, parent byte, dur time.Duration, success bool) hydrant.Event {
+		return hydrant.Event{
+			hydrant.TraceId("trace_id", [16]byte{trace}),
+			hydrant.SpanId("span_id", [8]byte{id}),
+			hydrant.SpanId("parent_id", [8]byte{parent}),
+			hydrant.Duration("duration", dur),
+			hydrant.Bool("success", success),
+		}
+	}
+	received := func() uint64 { return null.stats.received.Load() }
+
+	// a failed root keeps the whole trace, including late spans.
+	ts.Submit(t.Context(), span(1, 2, 1, time.Millisecond, true))
+	assert.Equal(t, received(), uint64(0))
+	ts.Submit(t.Context(), span(1, 1, 1, time.Millisecond, false))
+	assert.Equal(t, received(), uint64(2))
+	ts.Submit(t.Context(), span(1, 3, 1, time.Millisecond, true))
+	assert.Equal(t, received(), uint64(3))
+
+	// fast and successful traces are dropped.
+	ts.Submit(t.Context(), span(2, 2, 1, time.Millisecond, true))
+	ts.Submit(t.Context(), span(2, 1, 1, time.Millisecond, true))
+	assert.Equal(t, received(), uint64(3))
+
+	// slow roots are kept.
+	ts.Submit(t.Context(), span(3, 1, 1, 2*time.Second, true))
+	assert.Equal(t, received(), uint64(4))
+
+	// events without a trace pass through.
+	ts.Submit(t.Context(), hydrant.Event{hydrant.String("message", "hi")})
+	assert.Equal(t, received(), uint64(5))
+
+	// flushing decides traces that are still waiting for their root.
+	ts.Submit(t.Context(), span(4, 2, 1, time.Millisecond, false))
+	assert.NoError(t, ts.Flush(t.Context()))
+	assert.Equal(t, received(), uint64(6))
+
+	assert.Equal(t, ts.stats.kept.Load(), uint64(3))
+	assert.Equal(t, ts.stats.dropped.Load(), uint64(1))
+
+	// decided traces don't pile up behind one that is still waiting for its root.
+	ts = NewTailSamplerSubmitter(TailPolicy{}, time.Minute, 4, 0, null)
+	ts.Submit(t.Context(), span(0, 2, 1, time.Millisecond, true))
+	for i := range 200 {
+		ts.Submit(t.Context(), span(byte(i+1), 1, 1, time.Millisecond, true))
+	}
+	assert.Equal(t, len(ts.pending), 1)
+	assert.That(t, len(ts.order) <= 2*4+1)
+}
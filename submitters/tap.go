@@ -0,0 +1,394 @@
+package submitters
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs/v2"
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/internal/utils"
+)
+
+const (
+	defaultTapTTL = 10 * time.Minute
+	maxTapTTL     = 24 * time.Hour
+)
+
+// the kinds of submitters a tap can send events to.
+const (
+	TapTraceBuffer = "trace_buffer"
+	TapStream      = "stream"
+)
+
+// TapConfig describes a debug pipeline to attach to a running ConfiguredSubmitter.
+type TapConfig struct {
+	// Path is the path of the handler of the submitter to tap, like "/sub/sub/0" or "/name/foo".
+	// The tap receives the events the submitter receives.
+	Path string `json:"path"`
+
+	// Filter selects what the tap keeps. For trace buffer taps it selects traces by their root
+	// span like the filter of a TraceBufferSubmitter, and for stream taps it selects events. It is
+	// empty to keep everything.
+	Filter string `json:"filter"`
+
+	// Kind is TapTraceBuffer (the default) to collect traces for the web UI, or TapStream to
+	// only make the events available on the live stream.
+	Kind string `json:"kind"`
+
+	// BufferSize is the number of traces kept by a trace buffer tap.
+	BufferSize int `json:"buffer_size,omitempty"`
+
+	// TTL is how long until the tap detaches itself. It defaults to 10 minutes.
+	TTL time.Duration `json:"-"`
+
+	// Persist keeps the tap attached across RemoteSubmitter config swaps if the new pipeline has
+	// a submitter at the same path. Otherwise the tap is dropped on a swap.
+	Persist bool `json:"persist"`
+}
+
+// TapInfo describes an attached tap.
+type TapInfo struct {
+	ID string `json:"id"`
+	TapConfig
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// tap is a filter feeding a trace buffer or null submitter that watches the live buffer of a
+// submitter. Watching is lossy, so a tap that falls behind misses events instead of slowing
+// down the pipeline.
+type tap struct {
+	info  TapInfo
+	sub   *FilterSubmitter
+	timer *time.Timer
+
+	mu     sync.Mutex
+	owner  *ConfiguredSubmitter
+	cancel func()
+	done   chan struct{}
+}
+
+func (t *tap) attach(owner *ConfiguredSubmitter, live *liveBuffer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		live.buf.Watch(ctx, func(ev hydrant.Event) { t.sub.Submit(ctx, ev) })
+	}()
+
+	t.mu.Lock()
+	t.owner, t.cancel, t.done = owner, cancel, done
+	t.mu.Unlock()
+}
+
+func (t *tap) detach() {
+	t.mu.Lock()
+	cancel, done := t.cancel, t.done
+	t.cancel, t.done = nil, nil
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (t *tap) expire() {
+	t.mu.Lock()
+	owner := t.owner
+	t.mu.Unlock()
+
+	owner.RemoveTap(t.info.ID)
+}
+
+// AddTap attaches a debug pipeline to the submitter at the path in cfg until its TTL expires or
+// it is removed. Taps are served under /taps in the handler and are listed in /tree.
+func (s *ConfiguredSubmitter) AddTap(cfg TapConfig) (TapInfo, error) {
+	live := liveOf(s.lookup(cfg.Path))
+	if live == nil {
+		return TapInfo{}, errs.Errorf("no submitter to tap at %q", cfg.Path)
+	}
+
+	fil, err := s.env.Filter.Parse(cfg.Filter)
+	if err != nil {
+		return TapInfo{}, err
+	}
+
+	var sub *FilterSubmitter
+	switch cfg.Kind {
+	case "", TapTraceBuffer:
+		cfg.Kind = TapTraceBuffer
+		all, err := s.env.Filter.Parse("")
+		if err != nil {
+			return TapInfo{}, err
+		}
+		sub = NewFilterSubmitter(all, NewTraceBufferSubmitter(cfg.BufferSize, fil))
+	case TapStream:
+		sub = NewFilterSubmitter(fil, NewNullSubmitter())
+	default:
+		return TapInfo{}, errs.Errorf("unknown tap kind %q", cfg.Kind)
+	}
+	sub.live.configure(0, s.env.Filter)
+	liveOf(sub.sub).configure(0, s.env.Filter)
+
+	if cfg.TTL == 0 {
+		cfg.TTL = defaultTapTTL
+	}
+	cfg.TTL = utils.Bound(cfg.TTL, [2]time.Duration{time.Second, maxTapTTL})
+
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+
+	now := time.Now()
+	t := &tap{
+		info: TapInfo{
+			ID:        hex.EncodeToString(id[:]),
+			TapConfig: cfg,
+			Created:   now,
+			Expires:   now.Add(cfg.TTL),
+		},
+		sub: sub,
+	}
+
+	// attach and start the timer before the tap is published, so that removing it always finds
+	// them. the timer can't remove the tap before it is in the map because that needs tapMu.
+	t.attach(s, live)
+
+	s.tapMu.Lock()
+	if s.taps == nil {
+		s.taps = make(map[string]*tap)
+	}
+	t.timer = time.AfterFunc(cfg.TTL, t.expire)
+	s.taps[t.info.ID] = t
+	s.tapMu.Unlock()
+
+	return t.info, nil
+}
+
+// RemoveTap detaches the tap with the id. It returns false if there is no such tap.
+func (s *ConfiguredSubmitter) RemoveTap(id string) bool {
+	s.tapMu.Lock()
+	t, ok := s.taps[id]
+	delete(s.taps, id)
+	s.tapMu.Unlock()
+
+	if ok {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		t.detach()
+	}
+	return ok
+}
+
+// Taps returns the attached taps, oldest first.
+func (s *ConfiguredSubmitter) Taps() []TapInfo {
+	s.tapMu.Lock()
+	defer s.tapMu.Unlock()
+
+	out := make([]TapInfo, 0, len(s.taps))
+	for _, t := range s.taps {
+		out = append(out, t.info)
+	}
+	slices.SortFunc(out, func(a, b TapInfo) int { return a.Created.Compare(b.Created) })
+	return out
+}
+
+func (s *ConfiguredSubmitter) tap(id string) *tap {
+	s.tapMu.Lock()
+	defer s.tapMu.Unlock()
+	return s.taps[id]
+}
+
+// removeTaps detaches every tap.
+func (s *ConfiguredSubmitter) removeTaps() {
+	for _, info := range s.Taps() {
+		s.RemoveTap(info.ID)
+	}
+}
+
+// adoptTaps moves the persistent taps of prev that have a submitter at the same path in s over to
+// s. The rest are left to be removed when prev stops running.
+func (s *ConfiguredSubmitter) adoptTaps(prev *ConfiguredSubmitter) {
+	if prev == nil {
+		return
+	}
+
+	prev.tapMu.Lock()
+	var adopt []*tap
+	for id, t := range prev.taps {
+		if t.info.Persist && liveOf(s.lookup(t.info.Path)) != nil {
+			adopt = append(adopt, t)
+			delete(prev.taps, id)
+		}
+	}
+	prev.tapMu.Unlock()
+
+	for _, t := range adopt {
+		t.detach()
+
+		s.tapMu.Lock()
+		if s.taps == nil {
+			s.taps = make(map[string]*tap)
+		}
+		s.taps[t.info.ID] = t
+		s.tapMu.Unlock()
+
+		t.attach(s, liveOf(s.lookup(t.info.Path)))
+	}
+}
+
+// lookup returns the submitter whose handler is at path, following named references.
+func (s *ConfiguredSubmitter) lookup(path string) Submitter {
+	var sub Submitter
+	var rest string
+	if r, ok := strings.CutPrefix(path, "/name/"); ok {
+		name, r, _ := strings.Cut(r, "/")
+		late, ok := s.named[name]
+		if !ok {
+			return nil
+		}
+		sub, rest = late, strings.TrimSuffix("/"+r, "/")
+	} else if r, ok := strings.CutPrefix(path, "/sub"); ok {
+		sub, rest = s.root, r
+	} else {
+		return nil
+	}
+
+	for rest != "" {
+		r, ok := strings.CutPrefix(rest, "/sub")
+		if !ok {
+			return nil
+		}
+		children := sub.Children()
+
+		if _, ok := unwrap(sub).(*MultiSubmitter); ok {
+			idx, r, _ := strings.Cut(strings.TrimPrefix(r, "/"), "/")
+			i, err := strconv.Atoi(idx)
+			if err != nil || i < 0 || i >= len(children) {
+				return nil
+			}
+			sub, rest = children[i], strings.TrimSuffix("/"+r, "/")
+		} else {
+			if len(children) != 1 {
+				return nil
+			}
+			sub, rest = children[0], r
+		}
+	}
+
+	return sub
+}
+
+// tree returns the tree of submitters with the taps attached to each one.
+func (s *ConfiguredSubmitter) tree() any {
+	tree := treeify(s).(map[string]any)
+
+	taps := s.Taps()
+	if len(taps) == 0 {
+		return tree
+	}
+	tree["taps"] = taps
+
+	byPath := make(map[string][]TapInfo)
+	for _, info := range taps {
+		byPath[info.Path] = append(byPath[info.Path], info)
+	}
+
+	var decorate func(node map[string]any, path string)
+	decorate = func(node map[string]any, path string) {
+		if taps := byPath[path]; len(taps) > 0 {
+			node["taps"] = taps
+		}
+		children, _ := node["sub"].([]any)
+		for i, child := range children {
+			childPath := path + "/sub"
+			if node["kind"] == "MultiSubmitter" {
+				childPath += "/" + strconv.Itoa(i)
+			}
+			decorate(child.(map[string]any), childPath)
+		}
+	}
+	if children, _ := tree["sub"].([]any); len(children) > 0 {
+		decorate(children[0].(map[string]any), "/sub")
+	}
+
+	return tree
+}
+
+// tapsHandler serves the taps:
+//
+//	GET    /taps            list the taps
+//	POST   /taps            attach the tap in the body, with a "ttl" like "5m"
+//	DELETE /taps/{id}       detach a tap
+//	*      /taps/{id}/...   the tap's filter submitter, e.g. /taps/{id}/sub/traces
+func (s *ConfiguredSubmitter) tapsHandler() http.Handler {
+	return hmux.Dir{
+		"": hmux.Method{
+			"GET": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(s.Taps())
+			}),
+			"POST": http.HandlerFunc(s.serveAddTap),
+		},
+		"*": hmux.Arg("id").Capture(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := hmux.Arg("id").Value(r.Context())
+			if r.Method == http.MethodDelete && (r.URL.Path == "" || r.URL.Path == "/") {
+				if !requireAdmin(w, r) {
+					return
+				}
+				if !s.RemoveTap(id) {
+					http.Error(w, "unknown tap", http.StatusNotFound)
+				}
+				return
+			}
+			t := s.tap(id)
+			if t == nil {
+				http.Error(w, "unknown tap", http.StatusNotFound)
+				return
+			}
+			t.sub.Handler().ServeHTTP(w, r)
+		})),
+	}
+}
+
+func (s *ConfiguredSubmitter) serveAddTap(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		TapConfig
+		TTL string `json:"ttl"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TapConfig.TTL = ttl
+	}
+
+	info, err := s.AddTap(req.TapConfig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
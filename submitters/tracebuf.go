@@ -30,6 +30,7 @@ type TraceBufferSubmitter struct {
 	fil  *filter.Filter
 	live liveBuffer
 
+	wait      lockTimer
 	mu        sync.Mutex
 	traces    []traceEntry               // completed traces ring buffer
 	completed map[[16]byte]int           // trace_id → slot index in traces
@@ -66,6 +67,10 @@ func (t *TraceBufferSubmitter) ExtraData() any {
 	return map[string]string{"filter": t.fil.Filter()}
 }
 
+func (t *TraceBufferSubmitter) lockWait() *lockTimer { return &t.wait }
+
+func (t *TraceBufferSubmitter) Flush(ctx context.Context) error { return nil }
+
 func (t *TraceBufferSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 	t.live.Record(ev)
 	t.stats.received.Add(1)
@@ -78,7 +83,7 @@ func (t *TraceBufferSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
 
 	isRoot := spanID == parentID
 
-	t.mu.Lock()
+	t.wait.lock(&t.mu)
 	defer t.mu.Unlock()
 
 	// Already completed — append late span.
@@ -141,26 +146,28 @@ func (t *TraceBufferSubmitter) insertCompleted(traceID [16]byte, spans []hydrant
 	t.stats.traces.Add(1)
 }
 
+func (t *TraceBufferSubmitter) Stats() []Stat {
+	t.mu.Lock()
+	completedTraces := uint64(len(t.completed))
+	pendingTraces := uint64(len(t.pending))
+	t.mu.Unlock()
+	return []Stat{
+		{"received", t.stats.received.Load()},
+		{"spans", t.stats.spans.Load()},
+		{"traces", t.stats.traces.Load()},
+		{"completed_traces", completedTraces},
+		{"pending_traces", pendingTraces},
+		{"evicted", t.stats.evicted.Load()},
+		{"filtered", t.stats.filtered.Load()},
+	}
+}
+
 func (t *TraceBufferSubmitter) Handler() http.Handler {
 	return hmux.Dir{
 		"/tree":   constJSONHandler(treeify(t)),
 		"/live":   t.live.Handler(),
 		"/traces": http.HandlerFunc(t.tracesHandler),
-		"/stats": statsHandler(func() []stat {
-			t.mu.Lock()
-			completedTraces := uint64(len(t.completed))
-			pendingTraces := uint64(len(t.pending))
-			t.mu.Unlock()
-			return []stat{
-				{"received", t.stats.received.Load()},
-				{"spans", t.stats.spans.Load()},
-				{"traces", t.stats.traces.Load()},
-				{"completed_traces", completedTraces},
-				{"pending_traces", pendingTraces},
-				{"evicted", t.stats.evicted.Load()},
-				{"filtered", t.stats.filtered.Load()},
-			}
-		}),
+		"/stats":  statsHandler(t.Stats),
 	}
 }
 
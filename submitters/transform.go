@@ -0,0 +1,342 @@
+package submitters
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zeebo/errs/v2"
+	"github.com/zeebo/hmux"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/value"
+)
+
+// Transform is one operation applied by a TransformSubmitter. It is given a copy of the event that
+// it may modify and returns the result.
+type Transform struct {
+	desc  string
+	apply func(ev hydrant.Event) hydrant.Event
+}
+
+// String returns a description of the operation.
+func (t Transform) String() string { return t.desc }
+
+// matchKey returns true if the key matches any of the globs, as in path.Match.
+func matchKey(globs []string, key string) bool {
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGlobs returns an error if any of the globs are malformed.
+func validateGlobs(globs []string) error {
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return errs.Errorf("invalid key pattern %q: %w", glob, err)
+		}
+	}
+	return nil
+}
+
+// TransformDrop removes the annotations with keys matching any of the globs.
+func TransformDrop(globs ...string) Transform {
+	return Transform{
+		desc: "drop " + strings.Join(globs, ","),
+		apply: func(ev hydrant.Event) hydrant.Event {
+			return slices.DeleteFunc(ev, func(a hydrant.Annotation) bool { return matchKey(globs, a.Key) })
+		},
+	}
+}
+
+// TransformKeep removes the annotations with keys that match none of the globs.
+func TransformKeep(globs ...string) Transform {
+	return Transform{
+		desc: "keep " + strings.Join(globs, ","),
+		apply: func(ev hydrant.Event) hydrant.Event {
+			return slices.DeleteFunc(ev, func(a hydrant.Annotation) bool { return !matchKey(globs, a.Key) })
+		},
+	}
+}
+
+// TransformRename renames the annotations with the key from to the key to, replacing any
+// existing annotations with the key to.
+func TransformRename(from, to string) Transform {
+	return Transform{
+		desc: "rename " + from + " to " + to,
+		apply: func(ev hydrant.Event) hydrant.Event {
+			if !slices.ContainsFunc(ev, func(a hydrant.Annotation) bool { return a.Key == from }) {
+				return ev
+			}
+			ev = slices.DeleteFunc(ev, func(a hydrant.Annotation) bool { return a.Key == to })
+			for i := range ev {
+				if ev[i].Key == from {
+					ev[i].Key = to
+				}
+			}
+			return ev
+		},
+	}
+}
+
+// TransformSet sets the annotation, replacing any existing annotations with its key.
+func TransformSet(ann hydrant.Annotation) Transform {
+	return Transform{
+		desc: "set " + ann.String(),
+		apply: func(ev hydrant.Event) hydrant.Event {
+			return setAnnotation(ev, ann)
+		},
+	}
+}
+
+// TransformCopy copies the value of the annotation with the key from to the key to.
+func TransformCopy(from, to string) Transform {
+	return Transform{
+		desc: "copy " + from + " to " + to,
+		apply: func(ev hydrant.Event) hydrant.Event {
+			for _, a := range ev {
+				if a.Key == from {
+					return setAnnotation(ev, hydrant.Annotation{Key: to, Value: a.Value})
+				}
+			}
+			return ev
+		},
+	}
+}
+
+// TransformHash replaces the values of the annotations with keys matching any of the globs with
+// the hex encoded HMAC-SHA256 of the value keyed by salt, truncated to 16 bytes.
+func TransformHash(salt string, globs ...string) Transform {
+	return Transform{
+		desc: "hash " + strings.Join(globs, ","),
+		apply: func(ev hydrant.Event) hydrant.Event {
+			for i, a := range ev {
+				if !matchKey(globs, a.Key) {
+					continue
+				}
+				data := a.Value.AppendTo(nil)
+				if s, ok := a.Value.String(); ok {
+					data = []byte(s)
+				}
+				ev[i] = hydrant.String(a.Key, hashString(salt, data))
+			}
+			return ev
+		},
+	}
+}
+
+// TransformTruncate shortens the string and bytes values of the annotations with keys matching
+// any of the globs to at most n bytes, without splitting UTF-8 characters in strings.
+func TransformTruncate(n int, globs ...string) Transform {
+	return Transform{
+		desc: "truncate " + strings.Join(globs, ",") + " to " + strconv.Itoa(n),
+		apply: func(ev hydrant.Event) hydrant.Event {
+			for i, a := range ev {
+				if !matchKey(globs, a.Key) {
+					continue
+				}
+				if s, ok := a.Value.String(); ok && len(s) > n {
+					cut := n
+					for cut > 0 && !utf8.RuneStart(s[cut]) {
+						cut--
+					}
+					ev[i] = hydrant.String(a.Key, s[:cut])
+				} else if b, ok := a.Value.Bytes(); ok && len(b) > n {
+					ev[i] = hydrant.Bytes(a.Key, b[:n:n])
+				}
+			}
+			return ev
+		},
+	}
+}
+
+// transformKinds are the kinds TransformConvert can convert values to.
+var transformKinds = map[string]value.Kind{
+	"string":   value.KindString,
+	"int":      value.KindInt,
+	"uint":     value.KindUint,
+	"float":    value.KindFloat,
+	"bool":     value.KindBool,
+	"duration": value.KindDuration,
+}
+
+// TransformConvert converts the value of the annotation with the key to the kind, one of string,
+// int, uint, float, bool or duration. Values that can not be converted are left alone.
+func TransformConvert(key, kind string) Transform {
+	return Transform{
+		desc: "convert " + key + " to " + kind,
+		apply: func(ev hydrant.Event) hydrant.Event {
+			for i, a := range ev {
+				if a.Key == key {
+					if conv, ok := convertValue(a.Value, transformKinds[kind]); ok {
+						ev[i].Value = conv
+					}
+				}
+			}
+			return ev
+		},
+	}
+}
+
+func convertValue(v value.Value, kind value.Kind) (value.Value, bool) {
+	if v.Kind() == kind {
+		return v, true
+	}
+	if kind == value.KindString {
+		return value.String(valueString(v)), true
+	}
+
+	s := valueString(v)
+	switch kind {
+	case value.KindInt:
+		if x, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return value.Int(x), true
+		}
+		if x, err := strconv.ParseFloat(s, 64); err == nil {
+			return value.Int(int64(x)), true
+		}
+	case value.KindUint:
+		if x, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return value.Uint(x), true
+		}
+	case value.KindFloat:
+		if x, err := strconv.ParseFloat(s, 64); err == nil {
+			return value.Float(x), true
+		}
+	case value.KindBool:
+		if x, err := strconv.ParseBool(s); err == nil {
+			return value.Bool(x), true
+		}
+	case value.KindDuration:
+		if x, err := time.ParseDuration(s); err == nil {
+			return value.Duration(x), true
+		}
+	}
+	return v, false
+}
+
+// TransformLookup sets the annotation with the key to to the entry in the table for the value of
+// the annotation with the key from, or to def if there is no entry and def is not empty.
+func TransformLookup(from, to string, table map[string]string, def string) Transform {
+	return Transform{
+		desc: "lookup " + from + " to " + to,
+		apply: func(ev hydrant.Event) hydrant.Event {
+			for _, a := range ev {
+				if a.Key != from {
+					continue
+				}
+				if v, ok := table[valueString(a.Value)]; ok {
+					return setAnnotation(ev, hydrant.String(to, v))
+				} else if def != "" {
+					return setAnnotation(ev, hydrant.String(to, def))
+				}
+				return ev
+			}
+			return ev
+		},
+	}
+}
+
+// setAnnotation replaces the annotations with the key of ann with ann, or appends it if there are
+// none.
+func setAnnotation(ev hydrant.Event, ann hydrant.Annotation) hydrant.Event {
+	i := slices.IndexFunc(ev, func(a hydrant.Annotation) bool { return a.Key == ann.Key })
+	if i < 0 {
+		return append(ev, ann)
+	}
+	ev[i] = ann
+	rest := slices.DeleteFunc(ev[i+1:], func(a hydrant.Annotation) bool { return a.Key == ann.Key })
+	return ev[:i+1+len(rest)]
+}
+
+// valueString formats the value as a string.
+func valueString(v value.Value) string {
+	switch v.Kind() {
+	case value.KindString:
+		s, _ := v.String()
+		return s
+	case value.KindTraceId:
+		x, _ := v.TraceId()
+		return hex.EncodeToString(x[:])
+	case value.KindSpanId:
+		x, _ := v.SpanId()
+		return hex.EncodeToString(x[:])
+	}
+	return fmt.Sprint(v.AsAny())
+}
+
+// TransformSubmitter applies a list of transforms to a copy of every event, in order, and passes
+// the result to its child. The submitted events are never modified.
+type TransformSubmitter struct {
+	transforms []Transform
+	sub        Submitter
+	live       liveBuffer
+
+	stats struct {
+		received atomic.Uint64
+	}
+}
+
+func NewTransformSubmitter(transforms []Transform, sub Submitter) *TransformSubmitter {
+	return &TransformSubmitter{
+		transforms: transforms,
+		sub:        sub,
+		live:       newLiveBuffer(),
+	}
+}
+
+func (t *TransformSubmitter) Children() []Submitter {
+	return []Submitter{t.sub}
+}
+
+func (t *TransformSubmitter) ExtraData() any {
+	ops := make([]string, len(t.transforms))
+	for i, tr := range t.transforms {
+		ops[i] = tr.String()
+	}
+	return map[string]any{"operations": ops}
+}
+
+func (t *TransformSubmitter) Flush(ctx context.Context) error { return flushChildren(ctx, t) }
+
+func (t *TransformSubmitter) Submit(ctx context.Context, ev hydrant.Event) {
+	t.live.Record(ev)
+	t.stats.received.Add(1)
+
+	t.sub.Submit(ctx, t.apply(ev))
+}
+
+// apply returns the transformed copy of the event.
+func (t *TransformSubmitter) apply(ev hydrant.Event) hydrant.Event {
+	out := slices.Clone(ev)
+	for _, tr := range t.transforms {
+		out = tr.apply(out)
+	}
+	return out
+}
+
+func (t *TransformSubmitter) Stats() []Stat {
+	return []Stat{
+		{"received", t.stats.received.Load()},
+	}
+}
+
+func (t *TransformSubmitter) Handler() http.Handler {
+	return hmux.Dir{
+		"/tree":  constJSONHandler(treeify(t)),
+		"/live":  t.live.Handler(),
+		"/sub":   t.sub.Handler(),
+		"/stats": statsHandler(t.Stats),
+	}
+}
@@ -0,0 +1,73 @@
+package submitters
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+
+	"storj.io/hydrant"
+	"storj.io/hydrant/config"
+	"storj.io/hydrant/filter"
+)
+
+func TestTransform(t *testing.T) {
+	var cfg config.Config
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"submitter": {
+			"kind": "transform",
+			"operations": [
+				{"op": "drop", "keys": ["http.*"]},
+				{"op": "rename", "key": "grpc.method", "to": "rpc.method"},
+				{"op": "set", "key": "team", "value": "storage"},
+				{"op": "hash", "keys": ["user"], "salt": "pepper"},
+				{"op": "truncate", "keys": ["message"], "length": 2},
+				{"op": "convert", "key": "code", "as": "int"},
+				{"op": "lookup", "key": "code", "to": "status", "table": {"404": "not found"}}
+			],
+			"submitter": {"kind": "null"}
+		}
+	}`), &cfg))
+
+	sub, err := Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.NoError(t, err)
+
+	ev := hydrant.Event{
+		hydrant.String("http.remote_addr", "10.0.0.1"),
+		hydrant.String("grpc.method", "Get"),
+		hydrant.String("user", "alice"),
+		hydrant.String("message", "héllo world"),
+		hydrant.String("code", "404"),
+	}
+	orig := slices.Clone(ev)
+	sub.Submit(t.Context(), ev)
+	assert.DeepEqual(t, ev, orig)
+
+	got := make(map[string]any)
+	for _, a := range sub.root.Children()[0].(*NullSubmitter).live.buf.Get()[0] {
+		got[a.Key] = a.Value.AsAny()
+	}
+	assert.Equal(t, len(got), 6)
+	assert.Equal(t, got["rpc.method"], "Get")
+	assert.Equal(t, got["team"], "storage")
+	assert.Equal(t, len(got["user"].(string)), 32)
+	assert.Equal(t, got["message"], "h")
+	assert.Equal(t, got["code"], int64(404))
+	assert.Equal(t, got["status"], "not found")
+
+	// the salt is a secret.
+	rec := httptest.NewRecorder()
+	sub.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/config", nil))
+	assert.That(t, !strings.Contains(rec.Body.String(), "pepper"))
+
+	// hashing requires a salt.
+	cfg.Submitter = config.TransformSubmitter{
+		Operations: []config.TransformOperation{{Op: "hash", Keys: []string{"user"}}},
+		Submitter:  config.NullSubmitter{},
+	}
+	_, err = Environment{Filter: filter.NewBuiltinEnvionment()}.New(cfg)
+	assert.Error(t, err)
+}
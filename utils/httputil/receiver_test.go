@@ -70,6 +70,8 @@ func TestProtocol(t *testing.T) {
 type loggingSub []hydrant.Event
 
 func (l *loggingSub) Submit(ctx context.Context, ev hydrant.Event) { *l = append(*l, ev) }
+func (l *loggingSub) Flush(ctx context.Context) error              { return nil }
+func (l *loggingSub) Stats() []submitters.Stat                     { return nil }
 func (l *loggingSub) Children() []submitters.Submitter             { return nil }
 func (l *loggingSub) Handler() http.Handler                        { return nil }
 func (l *loggingSub) ExtraData() any                               { return nil }